@@ -1,3 +1,5 @@
 module github.com/netology-code/remux
 
-go 1.14
+go 1.21
+
+require github.com/google/uuid v1.1.1
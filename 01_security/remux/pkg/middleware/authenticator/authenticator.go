@@ -4,9 +4,29 @@ import (
 	"context"
 	"errors"
 	"net/http"
+	"strconv"
+	"strings"
 )
 
-var ErrNoAuthentication = errors.New("no authentication")
+var (
+	ErrNoAuthentication  = errors.New("no authentication")
+	ErrUnsupportedScheme = errors.New("unsupported authentication scheme")
+)
+
+// Ошибки, которые IdentifierFunc и UserDetailsFunc могут вернуть (или завернуть через %w),
+// чтобы Authenticator выбрал код ответа. Остальные ошибки, как и раньше, дают 401.
+var (
+	// ErrNoCredentials - клиент не передал учётные данные (401)
+	ErrNoCredentials = errors.New("no credentials")
+	// ErrInvalidCredentials - учётные данные неверны или неизвестны (401)
+	ErrInvalidCredentials = errors.New("invalid credentials")
+	// ErrExpiredCredentials - срок действия учётных данных истёк (401)
+	ErrExpiredCredentials = errors.New("expired credentials")
+	// ErrUnavailable - хранилище пользователей недоступно, запрос можно повторить позже (503)
+	ErrUnavailable = errors.New("authentication backend unavailable")
+	// ErrInternal - внутренняя ошибка при поиске пользователя (500)
+	ErrInternal = errors.New("authentication internal error")
+)
 
 var authenticationContextKey = &contextKey{"authentication context"}
 
@@ -22,18 +42,56 @@ type IdentifierFunc func(ctx context.Context) (*string, error)
 
 type UserDetailsFunc func(ctx context.Context, id *string) (interface{}, error)
 
+// SchemeFunc возвращает схему, которой клиент передал учётные данные (её определяет identificator)
+type SchemeFunc func(ctx context.Context) (string, error)
+
+// ErrorFunc формирует ответ на ошибку аутентификации. К моменту вызова заголовки
+// WWW-Authenticate (для 401) и Retry-After (для 503) уже установлены.
+type ErrorFunc func(writer http.ResponseWriter, request *http.Request, status int, err error)
+
+type Config struct {
+	Identifier  IdentifierFunc
+	UserDetails UserDetailsFunc
+	// Realm - значение параметра realm в WWW-Authenticate
+	Realm string
+	// Challenges - схемы, перечисляемые в WWW-Authenticate, по умолчанию только Bearer
+	Challenges []string
+	// RetryAfter - значение Retry-After в секундах для ответа 503 (0 - не отправлять)
+	RetryAfter int
+	// OnError - свой ответ на ошибку, по умолчанию отправляется только код ответа
+	OnError ErrorFunc
+}
+
 func Authenticator(identifier IdentifierFunc, userDetails UserDetailsFunc) func(http.Handler) http.Handler {
+	return New(Config{Identifier: identifier, UserDetails: userDetails})
+}
+
+// New создаёт middleware, которое определяет пользователя и кладёт его профиль в контекст.
+// Ошибка IdentifierFunc считается отсутствием учётных данных (если это не ErrUnavailable).
+func New(config Config) func(http.Handler) http.Handler {
+	if len(config.Challenges) == 0 {
+		config.Challenges = []string{"Bearer"}
+	}
+	if config.OnError == nil {
+		config.OnError = func(writer http.ResponseWriter, request *http.Request, status int, err error) {
+			writer.WriteHeader(status)
+		}
+	}
+
 	return func(handler http.Handler) http.Handler {
 		return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
-			id, err := identifier(request.Context())
+			id, err := config.Identifier(request.Context())
 			if err != nil {
-				writer.WriteHeader(http.StatusUnauthorized)
+				if !errors.Is(err, ErrUnavailable) {
+					err = &identifierError{err: err}
+				}
+				config.fail(writer, request, err)
 				return
 			}
 
-			profile, err := userDetails(request.Context(), id)
+			profile, err := config.UserDetails(request.Context(), id)
 			if err != nil {
-				writer.WriteHeader(http.StatusUnauthorized)
+				config.fail(writer, request, err)
 				return
 			}
 
@@ -45,10 +103,92 @@ func Authenticator(identifier IdentifierFunc, userDetails UserDetailsFunc) func(
 	}
 }
 
+func (c *Config) fail(writer http.ResponseWriter, request *http.Request, err error) {
+	status := Status(err)
+	switch status {
+	case http.StatusUnauthorized:
+		for _, scheme := range c.Challenges {
+			writer.Header().Add("WWW-Authenticate", challenge(scheme, c.Realm, err))
+		}
+	case http.StatusServiceUnavailable:
+		if c.RetryAfter > 0 {
+			writer.Header().Set("Retry-After", strconv.Itoa(c.RetryAfter))
+		}
+	}
+	c.OnError(writer, request, status, err)
+}
+
+// Status возвращает код ответа для ошибки аутентификации
+func Status(err error) int {
+	switch {
+	case errors.Is(err, ErrUnavailable):
+		return http.StatusServiceUnavailable
+	case errors.Is(err, ErrInternal):
+		return http.StatusInternalServerError
+	default:
+		return http.StatusUnauthorized
+	}
+}
+
+// challenge формирует значение WWW-Authenticate. Для Bearer при неверном или просроченном токене
+// добавляется error="invalid_token" (RFC 6750, раздел 3), при отсутствии токена - только realm.
+func challenge(scheme string, realm string, err error) string {
+	params := make([]string, 0, 3)
+	if realm != "" {
+		params = append(params, "realm="+strconv.Quote(realm))
+	}
+	if strings.EqualFold(scheme, "Bearer") {
+		switch {
+		case errors.Is(err, ErrExpiredCredentials):
+			params = append(params, `error="invalid_token"`, `error_description="The access token expired"`)
+		case errors.Is(err, ErrInvalidCredentials):
+			params = append(params, `error="invalid_token"`)
+		}
+	}
+	if len(params) == 0 {
+		return scheme
+	}
+	return scheme + " " + strings.Join(params, ", ")
+}
+
+// identifierError - ошибка IdentifierFunc, для клиента означает отсутствие учётных данных
+type identifierError struct {
+	err error
+}
+
+func (e *identifierError) Error() string {
+	return e.err.Error()
+}
+
+func (e *identifierError) Unwrap() []error {
+	return []error{e.err, ErrNoCredentials}
+}
+
+// ByScheme выбирает UserDetailsFunc по схеме учётных данных:
+//
+//	authenticator.Authenticator(identificator.Identifier, authenticator.ByScheme(identificator.Scheme, map[string]authenticator.UserDetailsFunc{
+//		identificator.SchemeBearer: tokenDetails,
+//		identificator.SchemeBasic:  passwordDetails,
+//	}))
+//
+// Для схемы, которой нет в userDetails, возвращается ErrUnsupportedScheme.
+func ByScheme(scheme SchemeFunc, userDetails map[string]UserDetailsFunc) UserDetailsFunc {
+	return func(ctx context.Context, id *string) (interface{}, error) {
+		name, err := scheme(ctx)
+		if err != nil {
+			return nil, err
+		}
+		details, ok := userDetails[name]
+		if !ok {
+			return nil, ErrUnsupportedScheme
+		}
+		return details(ctx, id)
+	}
+}
+
 func Authentication(ctx context.Context) (interface{}, error) {
 	if value := ctx.Value(authenticationContextKey); value != nil {
 		return value, nil
 	}
 	return nil, ErrNoAuthentication
 }
-
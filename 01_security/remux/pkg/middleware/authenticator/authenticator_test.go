@@ -3,9 +3,12 @@ package authenticator
 import (
 	"bytes"
 	"context"
+	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"github.com/netology-code/remux/pkg/remux"
+	"reflect"
 	"testing"
 )
 
@@ -58,3 +61,100 @@ func TestAuthenticator(t *testing.T) {
 		}
 	}
 }
+
+func TestByScheme(t *testing.T) {
+	schemeContextKey := &contextKey{"scheme"}
+	userDetails := ByScheme(func(ctx context.Context) (string, error) {
+		scheme, ok := ctx.Value(schemeContextKey).(string)
+		if !ok {
+			return "", ErrNoAuthentication
+		}
+		return scheme, nil
+	}, map[string]UserDetailsFunc{
+		"Bearer": func(ctx context.Context, id *string) (interface{}, error) {
+			return "token:" + *id, nil
+		},
+		"Basic": func(ctx context.Context, id *string) (interface{}, error) {
+			return "login:" + *id, nil
+		},
+	})
+
+	tests := []struct {
+		name    string
+		scheme  string
+		want    interface{}
+		wantErr error
+	}{
+		{name: "Bearer", scheme: "Bearer", want: "token:id"},
+		{name: "Basic", scheme: "Basic", want: "login:id"},
+		{name: "unsupported", scheme: "Cookie", wantErr: ErrUnsupportedScheme},
+	}
+
+	for _, tt := range tests {
+		id := "id"
+		got, err := userDetails(context.WithValue(context.Background(), schemeContextKey, tt.scheme), &id)
+		if err != tt.wantErr {
+			t.Errorf("%s: got error %v, want %v", tt.name, err, tt.wantErr)
+		}
+		if got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestAuthenticatorErrors(t *testing.T) {
+	tests := []struct {
+		name          string
+		identifierErr error
+		detailsErr    error
+		wantStatus    int
+		wantChallenge []string
+	}{
+		{name: "no credentials", identifierErr: errors.New("no identifier"), wantStatus: http.StatusUnauthorized, wantChallenge: []string{`Bearer realm="api"`, `Basic realm="api"`}},
+		{name: "invalid", detailsErr: fmt.Errorf("token: %w", ErrInvalidCredentials), wantStatus: http.StatusUnauthorized, wantChallenge: []string{`Bearer realm="api", error="invalid_token"`, `Basic realm="api"`}},
+		{name: "expired", detailsErr: ErrExpiredCredentials, wantStatus: http.StatusUnauthorized, wantChallenge: []string{`Bearer realm="api", error="invalid_token", error_description="The access token expired"`, `Basic realm="api"`}},
+		{name: "unsupported scheme", detailsErr: ErrUnsupportedScheme, wantStatus: http.StatusUnauthorized, wantChallenge: []string{`Bearer realm="api"`, `Basic realm="api"`}},
+		{name: "unavailable", detailsErr: fmt.Errorf("db: %w", ErrUnavailable), wantStatus: http.StatusServiceUnavailable},
+		{name: "unavailable identifier", identifierErr: ErrUnavailable, wantStatus: http.StatusServiceUnavailable},
+		{name: "unknown", detailsErr: errors.New("user not found"), wantStatus: http.StatusUnauthorized, wantChallenge: []string{`Bearer realm="api"`, `Basic realm="api"`}},
+		{name: "internal", detailsErr: fmt.Errorf("%w: boom", ErrInternal), wantStatus: http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		var hookErr error
+		handler := New(Config{
+			Identifier: func(ctx context.Context) (*string, error) {
+				id := "token"
+				return &id, tt.identifierErr
+			},
+			UserDetails: func(ctx context.Context, id *string) (interface{}, error) {
+				return "USERPROFILE", tt.detailsErr
+			},
+			Realm:      "api",
+			Challenges: []string{"Bearer", "Basic"},
+			RetryAfter: 5,
+			OnError: func(writer http.ResponseWriter, request *http.Request, status int, err error) {
+				hookErr = err
+				writer.WriteHeader(status)
+			},
+		})(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+			t.Errorf("%s: handler must not be called", tt.name)
+		}))
+
+		response := httptest.NewRecorder()
+		handler.ServeHTTP(response, httptest.NewRequest(http.MethodGet, "/", nil))
+
+		if response.Code != tt.wantStatus {
+			t.Errorf("%s: got status %d, want %d", tt.name, response.Code, tt.wantStatus)
+		}
+		if got := response.Header().Values("WWW-Authenticate"); !reflect.DeepEqual(got, tt.wantChallenge) && len(got)+len(tt.wantChallenge) != 0 {
+			t.Errorf("%s: got challenges %q, want %q", tt.name, got, tt.wantChallenge)
+		}
+		if tt.wantStatus == http.StatusServiceUnavailable && response.Header().Get("Retry-After") != "5" {
+			t.Errorf("%s: no Retry-After", tt.name)
+		}
+		if hookErr == nil {
+			t.Errorf("%s: OnError not called", tt.name)
+		}
+	}
+}
@@ -0,0 +1,210 @@
+package authenticator
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	defaultCacheTTL         = time.Minute
+	defaultCacheMaxSize     = 1024
+	defaultCacheLoadTimeout = 10 * time.Second
+)
+
+// errLoadPanicked получают запросы, ждавшие загрузки, которая завершилась паникой
+var errLoadPanicked = fmt.Errorf("%w: user details lookup panicked", ErrInternal)
+
+type CacheConfig struct {
+	// TTL - время жизни найденного профиля, по умолчанию минута
+	TTL time.Duration
+	// NegativeTTL - время жизни ошибки для неизвестного идентификатора, 0 - ошибки не кэшируются
+	NegativeTTL time.Duration
+	// MaxSize - максимальное количество записей, при переполнении вытесняются давно не используемые
+	MaxSize int
+	// Negative решает, какие ошибки можно кэшировать, по умолчанию - ErrInvalidCredentials
+	// (ошибки недоступности хранилища кэшировать нельзя)
+	Negative func(err error) bool
+	// Expires возвращает срок действия учётных данных профиля (например, токена): запись не живёт дольше него.
+	// Нулевое время - срок не ограничен.
+	Expires func(profile interface{}) time.Time
+	// LoadTimeout - ограничение на загрузку профиля, по умолчанию 10 секунд. Загрузка общая для всех
+	// ждущих её запросов, поэтому она не прерывается, если клиент, начавший её, отключился.
+	LoadTimeout time.Duration
+}
+
+// Cache - кэширующая обёртка над UserDetailsFunc:
+//
+//	cache := authenticator.NewCache(securitySvc.UserDetails, authenticator.CacheConfig{TTL: time.Minute})
+//	authenticatorMd := authenticator.Authenticator(identifier, cache.UserDetails)
+//
+// Одновременные запросы с одним идентификатором выполняют один вызов UserDetailsFunc.
+// После выхода пользователя или изменения его ролей нужно вызвать Invalidate (или InvalidateIf).
+type Cache struct {
+	userDetails UserDetailsFunc
+	config      CacheConfig
+	now         func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	lru     *list.List // от недавно использованных к давно не использованным
+	calls   map[string]*call
+}
+
+type cacheEntry struct {
+	id      string
+	profile interface{}
+	err     error
+	expires time.Time
+}
+
+// call - выполняющийся вызов UserDetailsFunc, результат которого ждут остальные запросы
+type call struct {
+	done      chan struct{}
+	profile   interface{}
+	err       error
+	forgotten bool // Invalidate во время вызова: результат не кэшируется
+}
+
+func NewCache(userDetails UserDetailsFunc, config CacheConfig) *Cache {
+	if config.TTL <= 0 {
+		config.TTL = defaultCacheTTL
+	}
+	if config.MaxSize <= 0 {
+		config.MaxSize = defaultCacheMaxSize
+	}
+	if config.LoadTimeout <= 0 {
+		config.LoadTimeout = defaultCacheLoadTimeout
+	}
+	if config.Negative == nil {
+		config.Negative = func(err error) bool {
+			return errors.Is(err, ErrInvalidCredentials)
+		}
+	}
+	return &Cache{
+		userDetails: userDetails,
+		config:      config,
+		now:         time.Now,
+		entries:     make(map[string]*list.Element),
+		lru:         list.New(),
+		calls:       make(map[string]*call),
+	}
+}
+
+// UserDetails - UserDetailsFunc, возвращающая профиль из кэша или загружающая его
+func (c *Cache) UserDetails(ctx context.Context, id *string) (interface{}, error) {
+	if id == nil {
+		return c.userDetails(ctx, id)
+	}
+	key := *id
+
+	c.mu.Lock()
+	if element, ok := c.entries[key]; ok {
+		entry := element.Value.(*cacheEntry)
+		if c.now().Before(entry.expires) {
+			c.lru.MoveToFront(element)
+			c.mu.Unlock()
+			return entry.profile, entry.err
+		}
+		c.remove(element)
+	}
+	if existing, ok := c.calls[key]; ok {
+		c.mu.Unlock()
+		select {
+		case <-existing.done:
+			return existing.profile, existing.err
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	current := &call{done: make(chan struct{})}
+	c.calls[key] = current
+	c.mu.Unlock()
+
+	c.load(ctx, key, id, current)
+	return current.profile, current.err
+}
+
+// load выполняет вызов UserDetailsFunc для всех ждущих запросов. Вызов и ожидающие освобождаются
+// даже при панике в UserDetailsFunc (паника передаётся дальше).
+func (c *Cache) load(ctx context.Context, key string, id *string, current *call) {
+	completed := false
+	defer func() {
+		c.mu.Lock()
+		delete(c.calls, key)
+		if !completed {
+			current.profile, current.err = nil, errLoadPanicked
+		} else if !current.forgotten {
+			c.store(key, current.profile, current.err)
+		}
+		c.mu.Unlock()
+		close(current.done)
+	}()
+
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), c.config.LoadTimeout)
+	defer cancel()
+	current.profile, current.err = c.userDetails(ctx, id)
+	completed = true
+}
+
+// Invalidate удаляет из кэша профиль для идентификатора (например, после выхода пользователя)
+func (c *Cache) Invalidate(id string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if element, ok := c.entries[id]; ok {
+		c.remove(element)
+	}
+	if current, ok := c.calls[id]; ok {
+		current.forgotten = true
+	}
+}
+
+// InvalidateIf удаляет из кэша профили, для которых match вернула true (например, все токены пользователя,
+// роли которого изменились). Загружающиеся в этот момент профили не кэшируются.
+func (c *Cache) InvalidateIf(match func(profile interface{}) bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for element := c.lru.Front(); element != nil; {
+		next := element.Next()
+		if entry := element.Value.(*cacheEntry); entry.err == nil && match(entry.profile) {
+			c.remove(element)
+		}
+		element = next
+	}
+	for _, current := range c.calls {
+		current.forgotten = true
+	}
+}
+
+// store кэширует результат, вызывается под c.mu
+func (c *Cache) store(id string, profile interface{}, err error) {
+	ttl := c.config.TTL
+	if err != nil {
+		if c.config.NegativeTTL <= 0 || !c.config.Negative(err) {
+			return
+		}
+		ttl = c.config.NegativeTTL
+	}
+
+	expires := c.now().Add(ttl)
+	if err == nil && c.config.Expires != nil {
+		if limit := c.config.Expires(profile); !limit.IsZero() && limit.Before(expires) {
+			expires = limit
+		}
+	}
+
+	c.entries[id] = c.lru.PushFront(&cacheEntry{id: id, profile: profile, err: err, expires: expires})
+	for c.lru.Len() > c.config.MaxSize {
+		c.remove(c.lru.Back())
+	}
+}
+
+func (c *Cache) remove(element *list.Element) {
+	c.lru.Remove(element)
+	delete(c.entries, element.Value.(*cacheEntry).id)
+}
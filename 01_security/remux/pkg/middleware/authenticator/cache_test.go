@@ -0,0 +1,211 @@
+package authenticator
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCache(t *testing.T) {
+	var calls int32
+	cache := NewCache(func(ctx context.Context, id *string) (interface{}, error) {
+		atomic.AddInt32(&calls, 1)
+		if *id == "unknown" {
+			return nil, ErrInvalidCredentials
+		}
+		if *id == "broken" {
+			return nil, ErrUnavailable
+		}
+		return "profile:" + *id, nil
+	}, CacheConfig{TTL: time.Minute, NegativeTTL: time.Second, MaxSize: 2})
+	now := time.Now()
+	cache.now = func() time.Time { return now }
+
+	lookup := func(id string) (interface{}, error) {
+		return cache.UserDetails(context.Background(), &id)
+	}
+	assertCalls := func(step string, want int32) {
+		t.Helper()
+		if got := atomic.SwapInt32(&calls, 0); got != want {
+			t.Errorf("%s: got %d calls, want %d", step, got, want)
+		}
+	}
+
+	if profile, err := lookup("a"); err != nil || profile != "profile:a" {
+		t.Fatalf("got %v, %v", profile, err)
+	}
+	lookup("a")
+	assertCalls("cached", 1)
+
+	now = now.Add(2 * time.Minute)
+	lookup("a")
+	assertCalls("expired", 1)
+
+	if _, err := lookup("unknown"); err != ErrInvalidCredentials {
+		t.Fatalf("got %v, want %v", err, ErrInvalidCredentials)
+	}
+	lookup("unknown")
+	assertCalls("negative", 1)
+
+	lookup("broken")
+	lookup("broken")
+	assertCalls("unavailable is not cached", 2)
+
+	// "a" и "unknown" в кэше, "b" вытесняет давно не использованный "a"
+	lookup("unknown")
+	lookup("b")
+	lookup("a")
+	assertCalls("lru", 2)
+
+	cache.Invalidate("a")
+	lookup("a")
+	assertCalls("invalidate", 1)
+
+	cache.InvalidateIf(func(profile interface{}) bool { return profile == "profile:a" })
+	lookup("a")
+	assertCalls("invalidate if", 1)
+}
+
+func TestCacheSingleflight(t *testing.T) {
+	var calls int32
+	release := make(chan struct{})
+	cache := NewCache(func(ctx context.Context, id *string) (interface{}, error) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		return "profile", nil
+	}, CacheConfig{})
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 10)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			id := "token"
+			if profile, err := cache.UserDetails(context.Background(), &id); err != nil || profile != "profile" {
+				errs <- errors.New("unexpected result")
+			}
+		}()
+	}
+
+	// ждём, пока первый вызов начнётся, остальные горутины присоединятся к нему
+	for atomic.LoadInt32(&calls) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Error(err)
+	}
+	if got := atomic.LoadInt32(&calls); got != 1 {
+		t.Errorf("got %d calls, want 1", got)
+	}
+}
+
+func TestCacheExpires(t *testing.T) {
+	now := time.Now()
+	var calls int32
+	cache := NewCache(func(ctx context.Context, id *string) (interface{}, error) {
+		atomic.AddInt32(&calls, 1)
+		return now.Add(10 * time.Second), nil
+	}, CacheConfig{
+		TTL:     time.Minute,
+		Expires: func(profile interface{}) time.Time { return profile.(time.Time) },
+	})
+	cache.now = func() time.Time { return now }
+
+	id := "token"
+	cache.UserDetails(context.Background(), &id)
+	now = now.Add(5 * time.Second)
+	cache.UserDetails(context.Background(), &id)
+	now = now.Add(10 * time.Second)
+	cache.UserDetails(context.Background(), &id)
+	if got := atomic.LoadInt32(&calls); got != 2 {
+		t.Errorf("got %d calls, want 2 (entry must expire with credentials)", got)
+	}
+}
+
+func TestCacheLeaderCancel(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	cache := NewCache(func(ctx context.Context, id *string) (interface{}, error) {
+		close(started)
+		<-release
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		return "profile", nil
+	}, CacheConfig{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	leader := make(chan error, 1)
+	go func() {
+		id := "token"
+		_, err := cache.UserDetails(ctx, &id)
+		leader <- err
+	}()
+	<-started
+
+	waiter := make(chan interface{}, 1)
+	go func() {
+		id := "token"
+		profile, _ := cache.UserDetails(context.Background(), &id)
+		waiter <- profile
+	}()
+	time.Sleep(10 * time.Millisecond)
+	cancel()
+	close(release)
+
+	if err := <-leader; err != nil {
+		t.Errorf("leader: got %v, want nil", err)
+	}
+	if got := <-waiter; got != "profile" {
+		t.Errorf("waiter: got %v, want profile", got)
+	}
+}
+
+func TestCachePanic(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	cache := NewCache(func(ctx context.Context, id *string) (interface{}, error) {
+		close(started)
+		<-release
+		panic("boom")
+	}, CacheConfig{})
+
+	go func() {
+		defer func() { recover() }()
+		id := "token"
+		cache.UserDetails(context.Background(), &id)
+	}()
+	<-started
+
+	waiter := make(chan error, 1)
+	go func() {
+		id := "token"
+		_, err := cache.UserDetails(context.Background(), &id)
+		waiter <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+
+	select {
+	case err := <-waiter:
+		if err != errLoadPanicked {
+			t.Errorf("got %v, want %v", err, errLoadPanicked)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("waiter blocked after panic")
+	}
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	if len(cache.calls) != 0 {
+		t.Errorf("got %d pending calls, want 0", len(cache.calls))
+	}
+}
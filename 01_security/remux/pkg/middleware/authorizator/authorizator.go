@@ -3,9 +3,9 @@ package authorizator
 import (
 	"context"
 	"net/http"
+	"strings"
 )
 
-
 type HasAnyRoleFunc func(ctx context.Context, roles ...string) bool
 
 func Authorizator(hasAnyRole HasAnyRoleFunc, roles ...string) func(http.Handler) http.Handler {
@@ -20,3 +20,227 @@ func Authorizator(hasAnyRole HasAnyRoleFunc, roles ...string) func(http.Handler)
 		})
 	}
 }
+
+// Subject - сведения о пользователе, по которым принимается решение о доступе
+type Subject struct {
+	ID    string
+	Roles []string
+	// Scopes ограничивает роли субъекта (например, для API-ключа, выпущенного с частью ролей владельца):
+	// nil - без ограничений, иначе учитываются только роли, входящие и в Roles, и в Scopes
+	Scopes []string
+}
+
+// restricted возвращает субъекта с ролями, урезанными до Scopes
+func (s *Subject) restricted() *Subject {
+	if s.Scopes == nil {
+		return s
+	}
+	roles := make([]string, 0, len(s.Roles))
+	for _, role := range s.Roles {
+		for _, scope := range s.Scopes {
+			if role == scope {
+				roles = append(roles, role)
+				break
+			}
+		}
+	}
+	return &Subject{ID: s.ID, Roles: roles}
+}
+
+// SubjectFunc возвращает текущего пользователя (ошибка - пользователь не аутентифицирован)
+type SubjectFunc func(ctx context.Context) (*Subject, error)
+
+// ParamFunc возвращает параметр пути (например, remux.Param)
+type ParamFunc func(ctx context.Context, name string) (string, error)
+
+// CheckFunc - проверка доступа к запросу
+type CheckFunc func(request *http.Request) bool
+
+// Permissions - таблица роль → разрешения. Разрешение "orders:*" даёт все разрешения вида "orders:...",
+// разрешение "*" - все разрешения.
+type Permissions map[string][]string
+
+// Has проверяет, даёт ли хотя бы одна из ролей разрешение permission
+func (p Permissions) Has(roles []string, permission string) bool {
+	for _, role := range roles {
+		for _, granted := range p[role] {
+			if granted == permission || granted == "*" {
+				return true
+			}
+			if strings.HasSuffix(granted, ":*") && strings.HasPrefix(permission, granted[:len(granted)-1]) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// DeniedFunc формирует ответ на отказ в доступе (например, пишет его в журнал аудита)
+type DeniedFunc func(writer http.ResponseWriter, request *http.Request)
+
+type Config struct {
+	Subject     SubjectFunc
+	Permissions Permissions
+	// Param нужен для проверок, использующих параметры пути (Owner, policy-функция param)
+	Param ParamFunc
+	// OnDenied - свой ответ на отказ для (*Authorizer).Require, по умолчанию отправляется только 403
+	OnDenied DeniedFunc
+}
+
+// Authorizer создаёт проверки доступа для текущего пользователя:
+//
+//	authz := authorizator.New(authorizator.Config{Subject: subject, Permissions: permissions, Param: remux.Param})
+//	mux.RegisterPattern(remux.GET, "/users/{id}", handler, authorizator.Require(authorizator.AnyOf(authz.Owner("id"), authz.HasAnyRole("ADMIN"))))
+type Authorizer struct {
+	config Config
+}
+
+func New(config Config) *Authorizer {
+	return &Authorizer{config: config}
+}
+
+// Require пропускает запрос, только если все проверки прошли, иначе отвечает 403
+func Require(checks ...CheckFunc) func(http.Handler) http.Handler {
+	return require(forbidden, checks...)
+}
+
+// Require - как authorizator.Require, но отказ обрабатывает Config.OnDenied
+func (a *Authorizer) Require(checks ...CheckFunc) func(http.Handler) http.Handler {
+	onDenied := a.config.OnDenied
+	if onDenied == nil {
+		onDenied = forbidden
+	}
+	return require(onDenied, checks...)
+}
+
+func require(onDenied DeniedFunc, checks ...CheckFunc) func(http.Handler) http.Handler {
+	check := AllOf(checks...)
+	return func(handler http.Handler) http.Handler {
+		return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+			if !check(request) {
+				onDenied(writer, request)
+				return
+			}
+
+			handler.ServeHTTP(writer, request)
+		})
+	}
+}
+
+func forbidden(writer http.ResponseWriter, request *http.Request) {
+	writer.WriteHeader(http.StatusForbidden)
+}
+
+func AllOf(checks ...CheckFunc) CheckFunc {
+	return func(request *http.Request) bool {
+		for _, check := range checks {
+			if !check(request) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+func AnyOf(checks ...CheckFunc) CheckFunc {
+	return func(request *http.Request) bool {
+		for _, check := range checks {
+			if check(request) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+func (a *Authorizer) HasAnyRole(roles ...string) CheckFunc {
+	return a.subjectCheck(func(subject *Subject) bool {
+		return hasAnyRole(subject, roles...)
+	})
+}
+
+func (a *Authorizer) HasAllRoles(roles ...string) CheckFunc {
+	return a.subjectCheck(func(subject *Subject) bool {
+		return hasAllRoles(subject, roles...)
+	})
+}
+
+// HasPermission проверяет, что роли пользователя дают все перечисленные разрешения
+func (a *Authorizer) HasPermission(permissions ...string) CheckFunc {
+	return a.subjectCheck(func(subject *Subject) bool {
+		for _, permission := range permissions {
+			if !a.config.Permissions.Has(subject.Roles, permission) {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+// Owner проверяет, что параметр пути param совпадает с идентификатором пользователя (например, /users/{id})
+func (a *Authorizer) Owner(param string) CheckFunc {
+	return a.Resource(func(request *http.Request, subject *Subject) bool {
+		value, err := a.param(request.Context(), param)
+		return err == nil && value == subject.ID
+	})
+}
+
+// Resource - произвольная проверка доступа к ресурсу, вызывается только для аутентифицированного пользователя
+func (a *Authorizer) Resource(check func(request *http.Request, subject *Subject) bool) CheckFunc {
+	return func(request *http.Request) bool {
+		subject, err := a.subject(request.Context())
+		if err != nil {
+			return false
+		}
+		return check(request, subject)
+	}
+}
+
+func (a *Authorizer) subjectCheck(check func(subject *Subject) bool) CheckFunc {
+	return a.Resource(func(request *http.Request, subject *Subject) bool {
+		return check(subject)
+	})
+}
+
+// subject возвращает текущего пользователя с учётом Subject.Scopes
+func (a *Authorizer) subject(ctx context.Context) (*Subject, error) {
+	subject, err := a.config.Subject(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return subject.restricted(), nil
+}
+
+func (a *Authorizer) param(ctx context.Context, name string) (string, error) {
+	if a.config.Param == nil {
+		return "", ErrNoParamFunc
+	}
+	return a.config.Param(ctx, name)
+}
+
+func hasAnyRole(subject *Subject, roles ...string) bool {
+	for _, role := range roles {
+		if hasRole(subject, role) {
+			return true
+		}
+	}
+	return false
+}
+
+func hasAllRoles(subject *Subject, roles ...string) bool {
+	for _, role := range roles {
+		if !hasRole(subject, role) {
+			return false
+		}
+	}
+	return true
+}
+
+func hasRole(subject *Subject, role string) bool {
+	for _, r := range subject.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
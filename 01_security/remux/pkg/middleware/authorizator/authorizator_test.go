@@ -2,6 +2,7 @@ package authorizator
 
 import (
 	"context"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"github.com/netology-code/remux/pkg/remux"
@@ -46,3 +47,115 @@ func TestAuthorizator(t *testing.T) {
 		}
 	}
 }
+
+var subjectContextKey = &struct{ name string }{"subject"}
+
+func testAuthorizer() *Authorizer {
+	return New(Config{
+		Subject: func(ctx context.Context) (*Subject, error) {
+			subject, ok := ctx.Value(subjectContextKey).(*Subject)
+			if !ok {
+				return nil, errors.New("no subject")
+			}
+			return subject, nil
+		},
+		Permissions: Permissions{
+			"ADMIN":   {"*"},
+			"USER":    {"orders:read", "profile:*"},
+			"AUDITOR": {"reports:read"},
+		},
+		Param: func(ctx context.Context, name string) (string, error) {
+			return remux.Param(ctx, name)
+		},
+	})
+}
+
+func TestAuthorizer(t *testing.T) {
+	authz := testAuthorizer()
+
+	tests := []struct {
+		name    string
+		check   CheckFunc
+		subject *Subject
+		path    string
+		want    int
+	}{
+		{name: "any role", check: authz.HasAnyRole("ADMIN", "USER"), subject: &Subject{ID: "1", Roles: []string{"USER"}}, want: http.StatusOK},
+		{name: "any role anonymous", check: authz.HasAnyRole("USER"), want: http.StatusForbidden},
+		{name: "all roles", check: authz.HasAllRoles("USER", "AUDITOR"), subject: &Subject{ID: "1", Roles: []string{"USER", "AUDITOR"}}, want: http.StatusOK},
+		{name: "all roles missing", check: authz.HasAllRoles("USER", "AUDITOR"), subject: &Subject{ID: "1", Roles: []string{"USER"}}, want: http.StatusForbidden},
+		{name: "permission", check: authz.HasPermission("orders:read"), subject: &Subject{ID: "1", Roles: []string{"USER"}}, want: http.StatusOK},
+		{name: "permission wildcard", check: authz.HasPermission("profile:write"), subject: &Subject{ID: "1", Roles: []string{"USER"}}, want: http.StatusOK},
+		{name: "permission denied", check: authz.HasPermission("orders:write"), subject: &Subject{ID: "1", Roles: []string{"USER"}}, want: http.StatusForbidden},
+		{name: "permission all", check: authz.HasPermission("orders:write"), subject: &Subject{ID: "1", Roles: []string{"ADMIN"}}, want: http.StatusOK},
+		{name: "owner", check: authz.Owner("id"), subject: &Subject{ID: "1"}, path: "/users/1", want: http.StatusOK},
+		{name: "not owner", check: authz.Owner("id"), subject: &Subject{ID: "1"}, path: "/users/2", want: http.StatusForbidden},
+		{name: "scoped role", check: authz.HasAnyRole("USER"), subject: &Subject{ID: "1", Roles: []string{"ADMIN", "USER"}, Scopes: []string{"USER"}}, want: http.StatusOK},
+		{name: "scoped out role", check: authz.HasAnyRole("ADMIN"), subject: &Subject{ID: "1", Roles: []string{"ADMIN", "USER"}, Scopes: []string{"USER"}}, want: http.StatusForbidden},
+		{name: "scope beyond roles", check: authz.HasAnyRole("ADMIN"), subject: &Subject{ID: "1", Roles: []string{"USER"}, Scopes: []string{"ADMIN"}}, want: http.StatusForbidden},
+		{name: "scoped permission", check: authz.HasPermission("orders:write"), subject: &Subject{ID: "1", Roles: []string{"ADMIN", "USER"}, Scopes: []string{"USER"}}, want: http.StatusForbidden},
+		{name: "empty scopes", check: authz.HasAnyRole("USER"), subject: &Subject{ID: "1", Roles: []string{"USER"}, Scopes: []string{}}, want: http.StatusForbidden},
+		{name: "owner or admin", check: AnyOf(authz.Owner("id"), authz.HasAnyRole("ADMIN")), subject: &Subject{ID: "1", Roles: []string{"ADMIN"}}, path: "/users/2", want: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		mux := remux.NewReMux()
+		if err := mux.RegisterPattern(remux.GET, "/users/{id}", http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {}), Require(tt.check)); err != nil {
+			t.Fatal(err)
+		}
+
+		path := tt.path
+		if path == "" {
+			path = "/users/1"
+		}
+		request := httptest.NewRequest(http.MethodGet, path, nil)
+		if tt.subject != nil {
+			request = request.WithContext(context.WithValue(request.Context(), subjectContextKey, tt.subject))
+		}
+		response := httptest.NewRecorder()
+		mux.ServeHTTP(response, request)
+		if response.Code != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, response.Code, tt.want)
+		}
+	}
+}
+
+func TestAuthorizerOnDenied(t *testing.T) {
+	authz := testAuthorizer()
+	denied := 0
+	authz.config.OnDenied = func(writer http.ResponseWriter, request *http.Request) {
+		denied++
+		writer.WriteHeader(http.StatusNotFound)
+	}
+
+	mux := remux.NewReMux()
+	if err := mux.RegisterPlain(remux.GET, "/admin", http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {}), authz.Require(authz.HasAnyRole("ADMIN"))); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		subject    *Subject
+		want       int
+		wantDenied int
+	}{
+		{name: "allowed", subject: &Subject{ID: "1", Roles: []string{"ADMIN"}}, want: http.StatusOK, wantDenied: 0},
+		{name: "denied", subject: &Subject{ID: "1", Roles: []string{"USER"}}, want: http.StatusNotFound, wantDenied: 1},
+		{name: "anonymous", want: http.StatusNotFound, wantDenied: 2},
+	}
+
+	for _, tt := range tests {
+		request := httptest.NewRequest(http.MethodGet, "/admin", nil)
+		if tt.subject != nil {
+			request = request.WithContext(context.WithValue(request.Context(), subjectContextKey, tt.subject))
+		}
+		response := httptest.NewRecorder()
+		mux.ServeHTTP(response, request)
+		if response.Code != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, response.Code, tt.want)
+		}
+		if denied != tt.wantDenied {
+			t.Errorf("%s: got %v denials, want %v", tt.name, denied, tt.wantDenied)
+		}
+	}
+}
@@ -0,0 +1,482 @@
+package authorizator
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrInvalidPolicy  = errors.New("invalid policy")
+	ErrPolicyNotFound = errors.New("policy not found")
+	ErrNoParamFunc    = errors.New("param func not configured")
+)
+
+// Policies - именованные правила доступа, заданные выражениями. Файл политик состоит из строк вида
+//
+//	# комментарий
+//	orders.read  = permission("orders:read")
+//	users.self   = authenticated() && (param("id") == user() || role("ADMIN"))
+//	reports.view = all_roles("USER", "AUDITOR") && method() == "GET"
+//
+// Логические функции: authenticated(), role(...) - любая из ролей, all_roles(...) - все роли,
+// permission(...) - все разрешения (по таблице Permissions), true, false.
+// Строковые функции: user() - идентификатор пользователя, param(name) - параметр пути,
+// query(name), header(name), method(). Строки сравниваются операторами == и !=,
+// отсутствующее значение (нет пользователя, параметра) не равно и не "не равно" ничему.
+// Операторы: !, &&, || и скобки, приоритет - как в Go.
+type Policies struct {
+	rules map[string]boolExpr
+}
+
+// LoadPolicies читает политики из файла
+func LoadPolicies(path string) (*Policies, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return ParsePolicies(file)
+}
+
+func ParsePolicies(reader io.Reader) (*Policies, error) {
+	policies := &Policies{rules: make(map[string]boolExpr)}
+	scanner := bufio.NewScanner(reader)
+	for number := 1; scanner.Scan(); number++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		name, source, ok := strings.Cut(line, "=")
+		name = strings.TrimSpace(name)
+		if !ok || !isPolicyName(name) {
+			return nil, fmt.Errorf("%w: line %d: expected <name> = <expression>", ErrInvalidPolicy, number)
+		}
+		if _, exists := policies.rules[name]; exists {
+			return nil, fmt.Errorf("%w: line %d: duplicate policy %s", ErrInvalidPolicy, number, name)
+		}
+
+		expr, err := parseExpression(source)
+		if err != nil {
+			return nil, fmt.Errorf("%w: line %d: %v", ErrInvalidPolicy, number, err)
+		}
+		policies.rules[name] = expr
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return policies, nil
+}
+
+func (p *Policies) Names() []string {
+	names := make([]string, 0, len(p.rules))
+	for name := range p.rules {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Policy возвращает проверку по политике name
+func (a *Authorizer) Policy(policies *Policies, name string) (CheckFunc, error) {
+	expr, ok := policies.rules[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrPolicyNotFound, name)
+	}
+	return func(request *http.Request) bool {
+		return expr(&env{authorizer: a, request: request})
+	}, nil
+}
+
+// env - данные, доступные выражению при проверке одного запроса
+type env struct {
+	authorizer *Authorizer
+	request    *http.Request
+	subject    *Subject
+	loaded     bool
+}
+
+func (e *env) user() (*Subject, bool) {
+	if !e.loaded {
+		e.loaded = true
+		if subject, err := e.authorizer.subject(e.request.Context()); err == nil {
+			e.subject = subject
+		}
+	}
+	return e.subject, e.subject != nil
+}
+
+type boolExpr func(e *env) bool
+
+// stringExpr возвращает ok = false, если значения нет
+type stringExpr func(e *env) (value string, ok bool)
+
+// operand - результат разбора подвыражения: логическое или строковое значение
+type operand struct {
+	b boolExpr
+	s stringExpr
+}
+
+func parseExpression(source string) (boolExpr, error) {
+	tokens, err := tokenize(source)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+	result, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if !p.done() {
+		return nil, fmt.Errorf("unexpected %q", p.peek().text)
+	}
+	if result.b == nil {
+		return nil, errors.New("expression must be boolean")
+	}
+	return result.b, nil
+}
+
+type tokenKind int
+
+const (
+	tokenIdent tokenKind = iota
+	tokenString
+	tokenOperator
+)
+
+type token struct {
+	kind tokenKind
+	text string
+}
+
+func tokenize(source string) ([]token, error) {
+	tokens := make([]token, 0)
+	for i := 0; i < len(source); {
+		c := source[i]
+		switch {
+		case c == ' ' || c == '\t':
+			i++
+		case c == '"':
+			end := i + 1
+			for end < len(source) && source[end] != '"' {
+				if source[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(source) {
+				return nil, errors.New("unterminated string")
+			}
+			value, err := strconv.Unquote(source[i : end+1])
+			if err != nil {
+				return nil, fmt.Errorf("invalid string %s", source[i:end+1])
+			}
+			tokens = append(tokens, token{kind: tokenString, text: value})
+			i = end + 1
+		case isIdentByte(c):
+			end := i
+			for end < len(source) && isIdentByte(source[end]) {
+				end++
+			}
+			tokens = append(tokens, token{kind: tokenIdent, text: source[i:end]})
+			i = end
+		default:
+			operator := ""
+			for _, candidate := range []string{"&&", "||", "==", "!=", "!", "(", ")", ","} {
+				if strings.HasPrefix(source[i:], candidate) {
+					operator = candidate
+					break
+				}
+			}
+			if operator == "" {
+				return nil, fmt.Errorf("unexpected character %q", c)
+			}
+			tokens = append(tokens, token{kind: tokenOperator, text: operator})
+			i += len(operator)
+		}
+	}
+	return tokens, nil
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) done() bool {
+	return p.pos >= len(p.tokens)
+}
+
+func (p *parser) peek() token {
+	if p.done() {
+		return token{kind: tokenOperator, text: "end of expression"}
+	}
+	return p.tokens[p.pos]
+}
+
+func (p *parser) accept(operator string) bool {
+	if next := p.peek(); !p.done() && next.kind == tokenOperator && next.text == operator {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *parser) expect(operator string) error {
+	if !p.accept(operator) {
+		return fmt.Errorf("expected %q, got %q", operator, p.peek().text)
+	}
+	return nil
+}
+
+func (p *parser) or() (operand, error) {
+	left, err := p.and()
+	if err != nil {
+		return operand{}, err
+	}
+	for p.accept("||") {
+		right, err := p.and()
+		if err != nil {
+			return operand{}, err
+		}
+		if left.b == nil || right.b == nil {
+			return operand{}, errors.New("operands of || must be boolean")
+		}
+		l, r := left.b, right.b
+		left = operand{b: func(e *env) bool { return l(e) || r(e) }}
+	}
+	return left, nil
+}
+
+func (p *parser) and() (operand, error) {
+	left, err := p.comparison()
+	if err != nil {
+		return operand{}, err
+	}
+	for p.accept("&&") {
+		right, err := p.comparison()
+		if err != nil {
+			return operand{}, err
+		}
+		if left.b == nil || right.b == nil {
+			return operand{}, errors.New("operands of && must be boolean")
+		}
+		l, r := left.b, right.b
+		left = operand{b: func(e *env) bool { return l(e) && r(e) }}
+	}
+	return left, nil
+}
+
+func (p *parser) comparison() (operand, error) {
+	left, err := p.unary()
+	if err != nil {
+		return operand{}, err
+	}
+	for _, operator := range []string{"==", "!="} {
+		if !p.accept(operator) {
+			continue
+		}
+		right, err := p.unary()
+		if err != nil {
+			return operand{}, err
+		}
+		if left.s == nil || right.s == nil {
+			return operand{}, fmt.Errorf("operands of %s must be strings", operator)
+		}
+		l, r, equal := left.s, right.s, operator == "=="
+		return operand{b: func(e *env) bool {
+			lv, lok := l(e)
+			rv, rok := r(e)
+			return lok && rok && (lv == rv) == equal
+		}}, nil
+	}
+	return left, nil
+}
+
+func (p *parser) unary() (operand, error) {
+	if p.accept("!") {
+		inner, err := p.unary()
+		if err != nil {
+			return operand{}, err
+		}
+		if inner.b == nil {
+			return operand{}, errors.New("operand of ! must be boolean")
+		}
+		b := inner.b
+		return operand{b: func(e *env) bool { return !b(e) }}, nil
+	}
+	return p.primary()
+}
+
+func (p *parser) primary() (operand, error) {
+	if p.accept("(") {
+		inner, err := p.or()
+		if err != nil {
+			return operand{}, err
+		}
+		return inner, p.expect(")")
+	}
+
+	next := p.peek()
+	if p.done() || next.kind == tokenOperator {
+		return operand{}, fmt.Errorf("unexpected %q", next.text)
+	}
+	p.pos++
+
+	if next.kind == tokenString {
+		value := next.text
+		return operand{s: func(e *env) (string, bool) { return value, true }}, nil
+	}
+
+	switch next.text {
+	case "true":
+		return operand{b: func(e *env) bool { return true }}, nil
+	case "false":
+		return operand{b: func(e *env) bool { return false }}, nil
+	}
+
+	args, err := p.arguments(next.text)
+	if err != nil {
+		return operand{}, err
+	}
+	return call(next.text, args)
+}
+
+// arguments разбирает список строковых литералов в скобках
+func (p *parser) arguments(function string) ([]string, error) {
+	if err := p.expect("("); err != nil {
+		return nil, fmt.Errorf("%s: %v", function, err)
+	}
+	args := make([]string, 0)
+	if p.accept(")") {
+		return args, nil
+	}
+	for {
+		next := p.peek()
+		if p.done() || next.kind != tokenString {
+			return nil, fmt.Errorf("%s: arguments must be string literals", function)
+		}
+		p.pos++
+		args = append(args, next.text)
+		if p.accept(")") {
+			return args, nil
+		}
+		if err := p.expect(","); err != nil {
+			return nil, fmt.Errorf("%s: %v", function, err)
+		}
+	}
+}
+
+func call(function string, args []string) (operand, error) {
+	arity := func(want int) error {
+		if len(args) != want {
+			return fmt.Errorf("%s expects %d argument(s), got %d", function, want, len(args))
+		}
+		return nil
+	}
+	atLeastOne := func() error {
+		if len(args) == 0 {
+			return fmt.Errorf("%s expects at least one argument", function)
+		}
+		return nil
+	}
+
+	switch function {
+	case "authenticated":
+		return operand{b: func(e *env) bool {
+			_, ok := e.user()
+			return ok
+		}}, arity(0)
+	case "role":
+		return operand{b: func(e *env) bool {
+			subject, ok := e.user()
+			return ok && hasAnyRole(subject, args...)
+		}}, atLeastOne()
+	case "all_roles":
+		return operand{b: func(e *env) bool {
+			subject, ok := e.user()
+			return ok && hasAllRoles(subject, args...)
+		}}, atLeastOne()
+	case "permission":
+		return operand{b: func(e *env) bool {
+			subject, ok := e.user()
+			if !ok {
+				return false
+			}
+			for _, permission := range args {
+				if !e.authorizer.config.Permissions.Has(subject.Roles, permission) {
+					return false
+				}
+			}
+			return true
+		}}, atLeastOne()
+	case "user":
+		return operand{s: func(e *env) (string, bool) {
+			subject, ok := e.user()
+			if !ok {
+				return "", false
+			}
+			return subject.ID, true
+		}}, arity(0)
+	case "method":
+		return operand{s: func(e *env) (string, bool) {
+			return e.request.Method, true
+		}}, arity(0)
+	case "param":
+		if err := arity(1); err != nil {
+			return operand{}, err
+		}
+		return operand{s: func(e *env) (string, bool) {
+			value, err := e.authorizer.param(e.request.Context(), args[0])
+			return value, err == nil
+		}}, nil
+	case "query":
+		if err := arity(1); err != nil {
+			return operand{}, err
+		}
+		return operand{s: func(e *env) (string, bool) {
+			values, ok := e.request.URL.Query()[args[0]]
+			if !ok || len(values) == 0 {
+				return "", false
+			}
+			return values[0], true
+		}}, nil
+	case "header":
+		if err := arity(1); err != nil {
+			return operand{}, err
+		}
+		return operand{s: func(e *env) (string, bool) {
+			values := e.request.Header.Values(args[0])
+			if len(values) == 0 {
+				return "", false
+			}
+			return values[0], true
+		}}, nil
+	default:
+		return operand{}, fmt.Errorf("unknown function %s", function)
+	}
+}
+
+func isIdentByte(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || c == '_'
+}
+
+func isPolicyName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i := 0; i < len(name); i++ {
+		if !isIdentByte(name[i]) && strings.IndexByte(".:-", name[i]) == -1 {
+			return false
+		}
+	}
+	return true
+}
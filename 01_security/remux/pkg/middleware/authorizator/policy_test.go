@@ -0,0 +1,103 @@
+package authorizator
+
+import (
+	"context"
+	"errors"
+	"github.com/netology-code/remux/pkg/remux"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const testPolicies = `
+# доступ к профилю: сам пользователь или администратор
+users.self    = authenticated() && (param("id") == user() || role("ADMIN"))
+orders.write  = permission("orders:write")
+reports.view  = all_roles("USER", "AUDITOR") && method() == "GET"
+debug         = !role("USER") && header("X-Debug") == "1"
+`
+
+func TestPolicies(t *testing.T) {
+	policies, err := ParsePolicies(strings.NewReader(testPolicies))
+	if err != nil {
+		t.Fatal(err)
+	}
+	authz := testAuthorizer()
+
+	tests := []struct {
+		name    string
+		policy  string
+		method  string
+		header  string
+		subject *Subject
+		want    int
+	}{
+		{name: "self", policy: "users.self", subject: &Subject{ID: "1"}, want: http.StatusOK},
+		{name: "other", policy: "users.self", subject: &Subject{ID: "2"}, want: http.StatusForbidden},
+		{name: "admin", policy: "users.self", subject: &Subject{ID: "2", Roles: []string{"ADMIN"}}, want: http.StatusOK},
+		{name: "anonymous", policy: "users.self", want: http.StatusForbidden},
+		{name: "permission", policy: "orders.write", subject: &Subject{ID: "2", Roles: []string{"ADMIN"}}, want: http.StatusOK},
+		{name: "no permission", policy: "orders.write", subject: &Subject{ID: "2", Roles: []string{"USER"}}, want: http.StatusForbidden},
+		{name: "all roles and method", policy: "reports.view", subject: &Subject{ID: "2", Roles: []string{"USER", "AUDITOR"}}, want: http.StatusOK},
+		{name: "wrong method", policy: "reports.view", method: http.MethodPost, subject: &Subject{ID: "2", Roles: []string{"USER", "AUDITOR"}}, want: http.StatusForbidden},
+		{name: "header", policy: "debug", header: "1", subject: &Subject{ID: "2"}, want: http.StatusOK},
+		{name: "no header", policy: "debug", subject: &Subject{ID: "2"}, want: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		check, err := authz.Policy(policies, tt.policy)
+		if err != nil {
+			t.Fatal(err)
+		}
+		method := tt.method
+		if method == "" {
+			method = http.MethodGet
+		}
+		mux := remux.NewReMux()
+		if err := mux.RegisterPattern(remux.Method(method), "/users/{id}", http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {}), Require(check)); err != nil {
+			t.Fatal(err)
+		}
+
+		request := httptest.NewRequest(method, "/users/1", nil)
+		if tt.header != "" {
+			request.Header.Set("X-Debug", tt.header)
+		}
+		if tt.subject != nil {
+			request = request.WithContext(context.WithValue(request.Context(), subjectContextKey, tt.subject))
+		}
+		response := httptest.NewRecorder()
+		mux.ServeHTTP(response, request)
+		if response.Code != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, response.Code, tt.want)
+		}
+	}
+
+	if _, err := authz.Policy(policies, "unknown"); !errors.Is(err, ErrPolicyNotFound) {
+		t.Errorf("got %v, want %v", err, ErrPolicyNotFound)
+	}
+}
+
+func TestParsePoliciesErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+	}{
+		{name: "no name", source: `= role("ADMIN")`},
+		{name: "duplicate", source: "a = true\na = false"},
+		{name: "unknown function", source: `a = admin()`},
+		{name: "not boolean", source: `a = user()`},
+		{name: "compare booleans", source: `a = role("ADMIN") == true`},
+		{name: "and strings", source: `a = user() && method()`},
+		{name: "arity", source: `a = param()`},
+		{name: "unterminated", source: `a = role("ADMIN)`},
+		{name: "unbalanced", source: `a = (role("ADMIN")`},
+		{name: "trailing", source: `a = role("ADMIN") role("USER")`},
+	}
+
+	for _, tt := range tests {
+		if _, err := ParsePolicies(strings.NewReader(tt.source)); !errors.Is(err, ErrInvalidPolicy) {
+			t.Errorf("%s: got %v, want %v", tt.name, err, ErrInvalidPolicy)
+		}
+	}
+}
@@ -3,7 +3,9 @@ package identificator
 import (
 	"context"
 	"errors"
+	"net"
 	"net/http"
+	"net/netip"
 	"strings"
 )
 
@@ -19,24 +21,160 @@ func (c *contextKey) String() string {
 	return c.name
 }
 
+type identity struct {
+	identifier string
+	addr       netip.Addr
+}
+
+// Заголовки, в которых прокси передаёт адрес клиента
+const (
+	HeaderForwarded     = "Forwarded"
+	HeaderXForwardedFor = "X-Forwarded-For"
+)
+
+type Config struct {
+	// TrustedProxies - сети прокси, которым разрешено сообщать адрес клиента
+	// в заголовке Header. Если список пуст, заголовки игнорируются.
+	TrustedProxies []netip.Prefix
+	// Header - заголовок, который дописывают доверенные прокси (по умолчанию X-Forwarded-For).
+	// Другие заголовки не читаются: их мог прислать сам клиент, прокси передаёт их без изменений.
+	Header string
+}
+
+var defaultMiddleware = New(Config{})
+
+// Identificator определяет клиента по адресу соединения (RemoteAddr), заголовки прокси не учитываются
 func Identificator(handler http.Handler) http.Handler {
-	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
-		// naive realization
-		parts := strings.Split(request.RemoteAddr, ":")
-		if len(parts) == 2 {
-			ctx := context.WithValue(request.Context(), identifierContextKey, &parts[0])
-			request = request.WithContext(ctx)
-		}
+	return defaultMiddleware(handler)
+}
 
-		handler.ServeHTTP(writer, request)
-	})
+// New создаёт middleware, которое определяет IP-адрес клиента и кладёт его в контекст.
+// Если соединение пришло от доверенного прокси, адрес берётся из заголовка config.Header
+// (Forwarded по RFC 7239 или список адресов, как в X-Forwarded-For): цепочка просматривается справа налево
+// до первого адреса, не принадлежащего доверенным прокси.
+func New(config Config) func(http.Handler) http.Handler {
+	if config.Header == "" {
+		config.Header = HeaderXForwardedFor
+	}
+	return func(handler http.Handler) http.Handler {
+		return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+			if addr, ok := remoteAddr(request.RemoteAddr); ok {
+				addr = clientAddr(addr, request.Header, config.Header, config.TrustedProxies)
+				ctx := context.WithValue(request.Context(), identifierContextKey, &identity{
+					identifier: addr.String(),
+					addr:       addr,
+				})
+				request = request.WithContext(ctx)
+			}
+
+			handler.ServeHTTP(writer, request)
+		})
+	}
 }
 
 func Identifier(ctx context.Context) (*string, error) {
-	value, ok := ctx.Value(identifierContextKey).(*string)
+	value, ok := ctx.Value(identifierContextKey).(*identity)
 	if !ok {
 		return nil, ErrNoIdentifier
 	}
-	return value, nil
+	return &value.identifier, nil
+}
+
+// Addr возвращает IP-адрес клиента, определённый Identificator
+func Addr(ctx context.Context) (netip.Addr, error) {
+	value, ok := ctx.Value(identifierContextKey).(*identity)
+	if !ok {
+		return netip.Addr{}, ErrNoIdentifier
+	}
+	return value.addr, nil
+}
+
+func remoteAddr(remote string) (netip.Addr, bool) {
+	host, _, err := net.SplitHostPort(remote)
+	if err != nil {
+		host = remote
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	return addr.Unmap(), true
+}
+
+func clientAddr(addr netip.Addr, header http.Header, name string, trusted []netip.Prefix) netip.Addr {
+	if !isTrusted(addr, trusted) {
+		return addr
+	}
+
+	var chain []string
+	if http.CanonicalHeaderKey(name) == HeaderForwarded {
+		chain = forwardedFor(header)
+	} else {
+		chain = addressList(header, name)
+	}
+
+	for i := len(chain) - 1; i >= 0; i-- {
+		hop, ok := parseHop(chain[i])
+		if !ok {
+			// неизвестный или скрытый адрес: дальше цепочке доверять нельзя
+			break
+		}
+		addr = hop
+		if !isTrusted(addr, trusted) {
+			break
+		}
+	}
+	return addr
 }
 
+func isTrusted(addr netip.Addr, trusted []netip.Prefix) bool {
+	for _, prefix := range trusted {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// forwardedFor возвращает значения параметра for из всех элементов заголовков Forwarded
+func forwardedFor(header http.Header) []string {
+	var result []string
+	for _, value := range header.Values("Forwarded") {
+		for _, element := range strings.Split(value, ",") {
+			for _, pair := range strings.Split(element, ";") {
+				name, value, ok := strings.Cut(strings.TrimSpace(pair), "=")
+				if ok && strings.EqualFold(name, "for") {
+					result = append(result, strings.Trim(value, `"`))
+				}
+			}
+		}
+	}
+	return result
+}
+
+// addressList возвращает адреса из заголовков вида X-Forwarded-For: 203.0.113.9, 198.51.100.1
+func addressList(header http.Header, name string) []string {
+	var result []string
+	for _, value := range header.Values(name) {
+		for _, hop := range strings.Split(value, ",") {
+			result = append(result, strings.TrimSpace(hop))
+		}
+	}
+	return result
+}
+
+// parseHop разбирает адрес из цепочки прокси: 192.0.2.1, 192.0.2.1:4711, 2001:db8::1, [2001:db8::1]:4711
+func parseHop(hop string) (netip.Addr, bool) {
+	if addr, err := netip.ParseAddr(hop); err == nil {
+		return addr.Unmap(), true
+	}
+	if addrPort, err := netip.ParseAddrPort(hop); err == nil {
+		return addrPort.Addr().Unmap(), true
+	}
+	if strings.HasPrefix(hop, "[") && strings.HasSuffix(hop, "]") {
+		if addr, err := netip.ParseAddr(hop[1 : len(hop)-1]); err == nil {
+			return addr.Unmap(), true
+		}
+	}
+	return netip.Addr{}, false
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"net/http"
 	"net/http/httptest"
+	"net/netip"
 	"github.com/netology-code/remux/pkg/remux"
 	"testing"
 )
@@ -38,6 +39,8 @@ func TestIdentificator(t *testing.T) {
 		want []byte
 	}{
 		{name: "GET", args: args{method: remux.GET, path: "/get", addr: "192.0.2.1:12345"}, want: []byte("192.0.2.1")},
+		{name: "GET IPv6", args: args{method: remux.GET, path: "/get", addr: "[2001:db8::1]:12345"}, want: []byte("2001:db8::1")},
+		{name: "GET IPv4-mapped", args: args{method: remux.GET, path: "/get", addr: "[::ffff:192.0.2.1]:12345"}, want: []byte("192.0.2.1")},
 		// TODO: write for other methods
 	}
 
@@ -53,3 +56,53 @@ func TestIdentificator(t *testing.T) {
 		}
 	}
 }
+
+func TestTrustedProxies(t *testing.T) {
+	var got netip.Addr
+	trusted := []netip.Prefix{
+		netip.MustParsePrefix("10.0.0.0/8"),
+		netip.MustParsePrefix("2001:db8:ffff::/48"),
+	}
+	target := http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		addr, err := Addr(request.Context())
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = addr
+	})
+	xForwardedFor := New(Config{TrustedProxies: trusted})(target)
+	forwarded := New(Config{TrustedProxies: trusted, Header: HeaderForwarded})(target)
+
+	tests := []struct {
+		name    string
+		handler http.Handler
+		addr    string
+		header  http.Header
+		want    string
+	}{
+		{name: "untrusted peer", handler: xForwardedFor, addr: "192.0.2.1:1234", header: http.Header{"X-Forwarded-For": {"198.51.100.1"}}, want: "192.0.2.1"},
+		{name: "no headers", handler: xForwardedFor, addr: "10.0.0.1:1234", header: http.Header{}, want: "10.0.0.1"},
+		{name: "x-forwarded-for", handler: xForwardedFor, addr: "10.0.0.1:1234", header: http.Header{"X-Forwarded-For": {"198.51.100.1"}}, want: "198.51.100.1"},
+		{name: "x-forwarded-for chain", handler: xForwardedFor, addr: "10.0.0.1:1234", header: http.Header{"X-Forwarded-For": {"203.0.113.9, 198.51.100.1, 10.0.0.2"}}, want: "198.51.100.1"},
+		{name: "x-forwarded-for multiple headers", handler: xForwardedFor, addr: "10.0.0.1:1234", header: http.Header{"X-Forwarded-For": {"203.0.113.9", "198.51.100.1"}}, want: "198.51.100.1"},
+		{name: "x-forwarded-for all trusted", handler: xForwardedFor, addr: "10.0.0.1:1234", header: http.Header{"X-Forwarded-For": {"10.0.0.3, 10.0.0.2"}}, want: "10.0.0.3"},
+		{name: "x-forwarded-for invalid", handler: xForwardedFor, addr: "10.0.0.1:1234", header: http.Header{"X-Forwarded-For": {"unknown"}}, want: "10.0.0.1"},
+		{name: "forwarded", handler: forwarded, addr: "10.0.0.1:1234", header: http.Header{"Forwarded": {"for=198.51.100.1;proto=https"}}, want: "198.51.100.1"},
+		{name: "forwarded IPv6 with port", handler: forwarded, addr: "[2001:db8:ffff::1]:1234", header: http.Header{"Forwarded": {`for="[2001:db8::2]:4711"`}}, want: "2001:db8::2"},
+		{name: "forwarded with x-forwarded-for", handler: forwarded, addr: "10.0.0.1:1234", header: http.Header{"Forwarded": {"for=198.51.100.1"}, "X-Forwarded-For": {"203.0.113.9"}}, want: "198.51.100.1"},
+		{name: "x-forwarded-for ignores forwarded", handler: xForwardedFor, addr: "10.0.0.1:1234", header: http.Header{"Forwarded": {"for=198.51.100.1"}, "X-Forwarded-For": {"203.0.113.9"}}, want: "203.0.113.9"},
+		{name: "forwarded ignores x-forwarded-for", handler: forwarded, addr: "10.0.0.1:1234", header: http.Header{"X-Forwarded-For": {"203.0.113.9"}}, want: "10.0.0.1"},
+		{name: "forwarded hidden", handler: forwarded, addr: "10.0.0.1:1234", header: http.Header{"Forwarded": {"for=203.0.113.9, for=_hidden"}}, want: "10.0.0.1"},
+	}
+
+	for _, tt := range tests {
+		request := httptest.NewRequest(http.MethodGet, "/", nil)
+		request.RemoteAddr = tt.addr
+		request.Header = tt.header
+
+		tt.handler.ServeHTTP(httptest.NewRecorder(), request)
+		if want := netip.MustParseAddr(tt.want); got != want {
+			t.Errorf("%s: got %s, want %s", tt.name, got, want)
+		}
+	}
+}
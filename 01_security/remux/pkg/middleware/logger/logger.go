@@ -1,13 +1,179 @@
 package logger
 
 import (
+	"context"
+	"github.com/netology-code/remux/pkg/middleware/identificator"
+	"github.com/netology-code/remux/pkg/middleware/requestid"
 	"log"
+	"net"
 	"net/http"
+	"os"
+	"time"
 )
 
+var captureContextKey = &contextKey{"logger capture context"}
+
+type contextKey struct {
+	name string
+}
+
+func (c *contextKey) String() string {
+	return c.name
+}
+
+// Entry - запись журнала доступа, формируется после завершения обработки запроса
+type Entry struct {
+	Time      time.Time
+	RequestID string
+	Method    string
+	Path      string
+	Route     string
+	Status    int
+	Bytes     int64
+	Duration  time.Duration
+	Remote    string
+	UserID    string
+}
+
+type RouteFunc func(ctx context.Context) (string, error)
+
+type IdentifierFunc func(ctx context.Context) (*string, error)
+
+type UserIDFunc func(ctx context.Context) (string, error)
+
+type RequestIDFunc func(ctx context.Context) (string, error)
+
+// Sampler решает, писать ли запись в журнал
+type Sampler func(entry *Entry) bool
+
+type Config struct {
+	// Sink - куда писать записи, по умолчанию logfmt в стандартный вывод ошибок
+	Sink Sink
+	// Route возвращает шаблон маршрута (например, remux.RoutePattern)
+	Route RouteFunc
+	// Identifier возвращает идентификатор клиента, по умолчанию identificator.Identifier (или RemoteAddr)
+	Identifier IdentifierFunc
+	// UserID возвращает идентификатор пользователя (например, из authenticator.Authentication)
+	UserID UserIDFunc
+	// RequestID возвращает идентификатор запроса, по умолчанию requestid.RequestIDFrom
+	RequestID RequestIDFunc
+	// Sampler - выборочная запись (например, Sample), по умолчанию пишутся все записи
+	Sampler Sampler
+}
+
+var defaultMiddleware = New(Config{})
+
+// Logger пишет журнал доступа с настройками по умолчанию
 func Logger(handler http.Handler) http.Handler {
+	return defaultMiddleware(handler)
+}
+
+// New создаёт middleware, которое после завершения обработки запроса пишет запись в журнал доступа.
+// Route, Identifier, UserID и RequestID вызываются для контекста самого внутреннего запроса,
+// который удалось получить (см. Capture), поэтому видят значения, добавленные middleware после логгера.
+func New(config Config) func(http.Handler) http.Handler {
+	if config.Sink == nil {
+		config.Sink = LogSink(log.New(os.Stderr, "", log.LstdFlags), Logfmt)
+	}
+	if config.Identifier == nil {
+		config.Identifier = identificator.Identifier
+	}
+	if config.RequestID == nil {
+		config.RequestID = requestid.RequestIDFrom
+	}
+
+	return func(handler http.Handler) http.Handler {
+		return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+			started := time.Now()
+			captured := &capture{ctx: request.Context()}
+			request = request.WithContext(context.WithValue(request.Context(), captureContextKey, captured))
+			recorder := &responseRecorder{ResponseWriter: writer}
+
+			defer func() {
+				entry := &Entry{
+					Time:     started,
+					Method:   request.Method,
+					Path:     request.URL.Path,
+					Status:   recorder.status,
+					Bytes:    recorder.bytes,
+					Duration: time.Since(started),
+					Remote:   remote(config.Identifier, captured.ctx, request),
+				}
+				if entry.Status == 0 {
+					entry.Status = http.StatusOK
+				}
+				if config.Route != nil {
+					entry.Route, _ = config.Route(captured.ctx)
+				}
+				entry.RequestID, _ = config.RequestID(captured.ctx)
+				if config.UserID != nil {
+					entry.UserID, _ = config.UserID(captured.ctx)
+				}
+				if config.Sampler == nil || config.Sampler(entry) {
+					config.Sink.Write(entry)
+				}
+			}()
+
+			handler.ServeHTTP(recorder, request)
+		})
+	}
+}
+
+// Capture сохраняет контекст запроса для журнала доступа. Его нужно ставить в цепочку после middleware,
+// которые добавляют в контекст данные для журнала (например, после authenticator.Authenticator).
+func Capture(handler http.Handler) http.Handler {
 	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
-		log.Printf("new request: %s %s", request.Method, request.URL.Path)
+		if captured, ok := request.Context().Value(captureContextKey).(*capture); ok {
+			captured.ctx = request.Context()
+		}
 		handler.ServeHTTP(writer, request)
 	})
 }
+
+type capture struct {
+	ctx context.Context
+}
+
+func remote(identifier IdentifierFunc, ctx context.Context, request *http.Request) string {
+	if id, err := identifier(ctx); err == nil {
+		return *id
+	}
+	if host, _, err := net.SplitHostPort(request.RemoteAddr); err == nil {
+		return host
+	}
+	return request.RemoteAddr
+}
+
+// responseRecorder запоминает код ответа и количество записанных байт
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func (r *responseRecorder) WriteHeader(statusCode int) {
+	if r.status == 0 {
+		r.status = statusCode
+	}
+	r.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (r *responseRecorder) Write(data []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	written, err := r.ResponseWriter.Write(data)
+	r.bytes += int64(written)
+	return written, err
+}
+
+func (r *responseRecorder) Flush() {
+	if flusher, ok := r.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Unwrap позволяет http.ResponseController добраться до исходного http.ResponseWriter
+func (r *responseRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
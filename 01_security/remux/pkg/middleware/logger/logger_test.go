@@ -0,0 +1,92 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/netology-code/remux/pkg/middleware/requestid"
+	"github.com/netology-code/remux/pkg/remux"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestLogger(t *testing.T) {
+	buffer := &bytes.Buffer{}
+	mux := remux.NewReMux(remux.WithOrder(remux.OrderDeclaration))
+	mux.Use(requestid.RequestID, New(Config{
+		Sink:  LogSink(log.New(buffer, "", 0), JSON),
+		Route: remux.RoutePattern,
+		UserID: func(ctx context.Context) (string, error) {
+			return ctx.Value(userContextKey).(string), nil
+		},
+	}))
+	setUser := func(handler http.Handler) http.Handler {
+		return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+			handler.ServeHTTP(writer, request.WithContext(context.WithValue(request.Context(), userContextKey, "42")))
+		})
+	}
+	if err := mux.RegisterPattern(
+		remux.POST,
+		"/users/{id}",
+		http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+			writer.WriteHeader(http.StatusCreated)
+			writer.Write([]byte("created"))
+		}),
+		setUser,
+		Capture,
+	); err != nil {
+		t.Fatal(err)
+	}
+
+	request := httptest.NewRequest(http.MethodPost, "/users/1", nil)
+	request.RemoteAddr = "192.0.2.1:12345"
+	request.Header.Set(requestid.Header, "abc-123")
+	mux.ServeHTTP(httptest.NewRecorder(), request)
+
+	got := &jsonEntry{}
+	if err := json.Unmarshal(buffer.Bytes(), got); err != nil {
+		t.Fatal(err)
+	}
+	want := jsonEntry{
+		Time:       got.Time,
+		RequestID:  "abc-123",
+		Method:     http.MethodPost,
+		Path:       "/users/1",
+		Route:      "/users/{id}",
+		Status:     http.StatusCreated,
+		Bytes:      int64(len("created")),
+		DurationMs: got.DurationMs,
+		Remote:     "192.0.2.1",
+		UserID:     "42",
+	}
+	if *got != want {
+		t.Errorf("got %v, want %v", *got, want)
+	}
+}
+
+func TestSample(t *testing.T) {
+	sampler := Sample(3, "/health")
+
+	tests := []struct {
+		name  string
+		entry *Entry
+		want  bool
+	}{
+		{name: "first", entry: &Entry{Route: "/health", Status: http.StatusOK}, want: true},
+		{name: "second", entry: &Entry{Route: "/health", Status: http.StatusOK}, want: false},
+		{name: "error", entry: &Entry{Route: "/health", Status: http.StatusInternalServerError}, want: true},
+		{name: "third", entry: &Entry{Route: "/health", Status: http.StatusOK}, want: false},
+		{name: "fourth", entry: &Entry{Route: "/health", Status: http.StatusOK}, want: true},
+		{name: "other route", entry: &Entry{Route: "/users", Status: http.StatusOK}, want: true},
+	}
+
+	for _, tt := range tests {
+		if got := sampler(tt.entry); got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+var userContextKey = &contextKey{"test user context"}
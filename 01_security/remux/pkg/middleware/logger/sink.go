@@ -0,0 +1,158 @@
+package logger
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"log/slog"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+type Format int
+
+const (
+	Logfmt Format = iota
+	JSON
+)
+
+// Sink получает готовые записи журнала доступа
+type Sink interface {
+	Write(entry *Entry)
+}
+
+type logSink struct {
+	logger *log.Logger
+	format Format
+}
+
+// LogSink пишет записи в *log.Logger в формате logfmt или JSON
+func LogSink(logger *log.Logger, format Format) Sink {
+	return &logSink{logger: logger, format: format}
+}
+
+func (s *logSink) Write(entry *Entry) {
+	if s.format == JSON {
+		data, err := json.Marshal(&jsonEntry{
+			Time:       entry.Time.Format(time.RFC3339Nano),
+			RequestID:  entry.RequestID,
+			Method:     entry.Method,
+			Path:       entry.Path,
+			Route:      entry.Route,
+			Status:     entry.Status,
+			Bytes:      entry.Bytes,
+			DurationMs: float64(entry.Duration) / float64(time.Millisecond),
+			Remote:     entry.Remote,
+			UserID:     entry.UserID,
+		})
+		if err != nil {
+			s.logger.Print(err)
+			return
+		}
+		s.logger.Print(string(data))
+		return
+	}
+
+	builder := strings.Builder{}
+	for index, field := range fields(entry) {
+		if index != 0 {
+			builder.WriteByte(' ')
+		}
+		builder.WriteString(field.key)
+		builder.WriteByte('=')
+		builder.WriteString(logfmtValue(field.value))
+	}
+	s.logger.Print(builder.String())
+}
+
+type jsonEntry struct {
+	Time       string  `json:"time"`
+	RequestID  string  `json:"request_id,omitempty"`
+	Method     string  `json:"method"`
+	Path       string  `json:"path"`
+	Route      string  `json:"route,omitempty"`
+	Status     int     `json:"status"`
+	Bytes      int64   `json:"bytes"`
+	DurationMs float64 `json:"duration_ms"`
+	Remote     string  `json:"remote,omitempty"`
+	UserID     string  `json:"user_id,omitempty"`
+}
+
+type slogSink struct {
+	handler slog.Handler
+}
+
+// SlogSink передаёт записи в slog.Handler (уровень Info, сообщение "request")
+func SlogSink(handler slog.Handler) Sink {
+	return &slogSink{handler: handler}
+}
+
+func (s *slogSink) Write(entry *Entry) {
+	ctx := context.Background()
+	if !s.handler.Enabled(ctx, slog.LevelInfo) {
+		return
+	}
+	record := slog.NewRecord(entry.Time, slog.LevelInfo, "request", 0)
+	record.AddAttrs(
+		slog.String("request_id", entry.RequestID),
+		slog.String("method", entry.Method),
+		slog.String("path", entry.Path),
+		slog.String("route", entry.Route),
+		slog.Int("status", entry.Status),
+		slog.Int64("bytes", entry.Bytes),
+		slog.Duration("duration", entry.Duration),
+		slog.String("remote", entry.Remote),
+		slog.String("user_id", entry.UserID),
+	)
+	_ = s.handler.Handle(ctx, record)
+}
+
+type field struct {
+	key   string
+	value string
+}
+
+func fields(entry *Entry) []field {
+	return []field{
+		{key: "time", value: entry.Time.Format(time.RFC3339Nano)},
+		{key: "request_id", value: entry.RequestID},
+		{key: "method", value: entry.Method},
+		{key: "path", value: entry.Path},
+		{key: "route", value: entry.Route},
+		{key: "status", value: strconv.Itoa(entry.Status)},
+		{key: "bytes", value: strconv.FormatInt(entry.Bytes, 10)},
+		{key: "duration", value: entry.Duration.String()},
+		{key: "remote", value: entry.Remote},
+		{key: "user_id", value: entry.UserID},
+	}
+}
+
+func logfmtValue(value string) string {
+	if value == "" || strings.ContainsAny(value, " =\"\t\r\n") {
+		return strconv.Quote(value)
+	}
+	return value
+}
+
+// Sample пишет только каждую every-ю запись для перечисленных маршрутов (по Entry.Route),
+// остальные маршруты и ответы с ошибками (код >= 400) пишутся всегда
+func Sample(every uint64, routes ...string) Sampler {
+	// карта только читается после создания, поэтому синхронизация нужна лишь для счётчиков
+	counters := make(map[string]*uint64, len(routes))
+	for _, route := range routes {
+		counters[route] = new(uint64)
+	}
+
+	return func(entry *Entry) bool {
+		if entry.Status >= 400 || every <= 1 {
+			return true
+		}
+		counter, ok := counters[entry.Route]
+		if !ok {
+			return true
+		}
+		return (atomic.AddUint64(counter, 1)-1)%every == 0
+	}
+}
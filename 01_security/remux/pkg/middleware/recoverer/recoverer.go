@@ -0,0 +1,120 @@
+package recoverer
+
+import (
+	"encoding/json"
+	"github.com/netology-code/remux/pkg/middleware/identificator"
+	"github.com/netology-code/remux/pkg/middleware/requestid"
+	"log"
+	"mime"
+	"net/http"
+	"runtime/debug"
+	"strings"
+)
+
+// problem - тело ответа в формате RFC 7807 (application/problem+json)
+type problem struct {
+	Type   string `json:"type"`
+	Title  string `json:"title"`
+	Status int    `json:"status"`
+}
+
+func Recoverer(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		recorder := &responseRecorder{ResponseWriter: writer}
+		defer func() {
+			err := recover()
+			if err == nil {
+				return
+			}
+			// net/http сам обрабатывает ErrAbortHandler: молча разрывает соединение
+			if err == http.ErrAbortHandler {
+				panic(err)
+			}
+
+			identifier := "-"
+			if id, e := identificator.Identifier(request.Context()); e == nil {
+				identifier = *id
+			}
+			requestID := "-"
+			if id, e := requestid.RequestIDFrom(request.Context()); e == nil {
+				requestID = id
+			}
+			log.Printf("panic: %v, request: %s %s, request id: %s, identifier: %s\n%s", err, request.Method, request.URL.Path, requestID, identifier, debug.Stack())
+
+			// заголовки уже отправлены - изменить ответ нельзя
+			if recorder.wroteHeader {
+				return
+			}
+			writeError(writer, request)
+		}()
+
+		handler.ServeHTTP(recorder, request)
+	})
+}
+
+func writeError(writer http.ResponseWriter, request *http.Request) {
+	if !acceptsJSON(request) {
+		writer.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	body, err := json.Marshal(&problem{
+		Type:   "about:blank",
+		Title:  http.StatusText(http.StatusInternalServerError),
+		Status: http.StatusInternalServerError,
+	})
+	if err != nil {
+		writer.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	writer.Header().Set("Content-Type", "application/problem+json")
+	writer.WriteHeader(http.StatusInternalServerError)
+	_, err = writer.Write(body)
+	if err != nil {
+		log.Print(err)
+	}
+}
+
+// acceptsJSON проверяет, принимает ли клиент application/problem+json (или application/json)
+func acceptsJSON(request *http.Request) bool {
+	for _, value := range strings.Split(request.Header.Get("Accept"), ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(value))
+		if err != nil {
+			continue
+		}
+		if mediaType == "application/problem+json" || mediaType == "application/json" {
+			return true
+		}
+	}
+	return false
+}
+
+// responseRecorder запоминает, начал ли обработчик отправлять ответ
+type responseRecorder struct {
+	http.ResponseWriter
+	wroteHeader bool
+}
+
+func (r *responseRecorder) WriteHeader(statusCode int) {
+	r.wroteHeader = true
+	r.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (r *responseRecorder) Write(data []byte) (int, error) {
+	r.wroteHeader = true
+	return r.ResponseWriter.Write(data)
+}
+
+// Flush считается началом ответа: заголовки уже отправлены
+func (r *responseRecorder) Flush() {
+	if flusher, ok := r.ResponseWriter.(http.Flusher); ok {
+		r.wroteHeader = true
+		flusher.Flush()
+	}
+}
+
+// Unwrap позволяет http.ResponseController добраться до исходного http.ResponseWriter
+func (r *responseRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
@@ -0,0 +1,78 @@
+package recoverer
+
+import (
+	"github.com/netology-code/remux/pkg/remux"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRecoverer(t *testing.T) {
+	mux := remux.NewReMux()
+	if err := mux.RegisterPlain(
+		remux.GET,
+		"/panic",
+		http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+			panic("boom")
+		}),
+		Recoverer,
+	); err != nil {
+		t.Fatal(err)
+	}
+
+	type args struct {
+		method remux.Method
+		path   string
+		accept string
+	}
+
+	tests := []struct {
+		name            string
+		args            args
+		want            int
+		wantContentType string
+	}{
+		{name: "GET", args: args{method: remux.GET, path: "/panic"}, want: http.StatusInternalServerError},
+		{name: "GET JSON", args: args{method: remux.GET, path: "/panic", accept: "application/problem+json"}, want: http.StatusInternalServerError, wantContentType: "application/problem+json"},
+	}
+
+	for _, tt := range tests {
+		request := httptest.NewRequest(string(tt.args.method), tt.args.path, nil)
+		request.Header.Set("Accept", tt.args.accept)
+		response := httptest.NewRecorder()
+		mux.ServeHTTP(response, request)
+		if got := response.Code; got != tt.want {
+			t.Errorf("got %v, want %v", got, tt.want)
+		}
+		if got := response.Header().Get("Content-Type"); got != tt.wantContentType {
+			t.Errorf("got %v, want %v", got, tt.wantContentType)
+		}
+	}
+}
+
+func TestRecoverer_ErrAbortHandler(t *testing.T) {
+	handler := Recoverer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		panic(http.ErrAbortHandler)
+	}))
+
+	defer func() {
+		if err := recover(); err != http.ErrAbortHandler {
+			t.Errorf("got %v, want %v", err, http.ErrAbortHandler)
+		}
+	}()
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+}
+
+func TestRecoverer_Flush(t *testing.T) {
+	handler := Recoverer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		if err := http.NewResponseController(writer).Flush(); err != nil {
+			t.Errorf("got %v, want nil", err)
+		}
+	}))
+
+	response := httptest.NewRecorder()
+	handler.ServeHTTP(response, httptest.NewRequest(http.MethodGet, "/", nil))
+	if !response.Flushed {
+		t.Error("response not flushed")
+	}
+}
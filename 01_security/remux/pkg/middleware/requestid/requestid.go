@@ -0,0 +1,93 @@
+package requestid
+
+import (
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"net/http"
+)
+
+// Header - заголовок, в котором передаётся идентификатор запроса
+const Header = "X-Request-ID"
+
+// maxLength ограничивает длину идентификатора, пришедшего от клиента
+const maxLength = 128
+
+var ErrNoRequestID = errors.New("no request id")
+
+var requestIDContextKey = &contextKey{"request id context"}
+
+type contextKey struct {
+	name string
+}
+
+func (c *contextKey) String() string {
+	return c.name
+}
+
+// RequestID берёт идентификатор запроса из заголовка X-Request-ID (или генерирует новый, если заголовка нет
+// или значение некорректно), кладёт его в контекст и возвращает клиенту в том же заголовке
+func RequestID(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		id := request.Header.Get(Header)
+		if !valid(id) {
+			id = uuid.New().String()
+		}
+
+		writer.Header().Set(Header, id)
+		ctx := WithRequestID(request.Context(), id)
+		handler.ServeHTTP(writer, request.WithContext(ctx))
+	})
+}
+
+// WithRequestID возвращает контекст с идентификатором запроса
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDContextKey, id)
+}
+
+func RequestIDFrom(ctx context.Context) (string, error) {
+	value, ok := ctx.Value(requestIDContextKey).(string)
+	if !ok {
+		return "", ErrNoRequestID
+	}
+	return value, nil
+}
+
+// Transport добавляет идентификатор запроса из контекста в исходящие запросы:
+//
+//	client := &http.Client{Transport: &requestid.Transport{}}
+type Transport struct {
+	// Base - используемый транспорт, по умолчанию http.DefaultTransport
+	Base http.RoundTripper
+}
+
+func (t *Transport) RoundTrip(request *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	id, err := RequestIDFrom(request.Context())
+	if err != nil || request.Header.Get(Header) != "" {
+		return base.RoundTrip(request)
+	}
+
+	// RoundTripper не должен изменять исходный запрос
+	request = request.Clone(request.Context())
+	request.Header.Set(Header, id)
+	return base.RoundTrip(request)
+}
+
+// valid пропускает только непустые значения разумной длины из видимых ASCII-символов,
+// чтобы клиент не мог подсунуть в журнал переводы строк или управляющие символы
+func valid(id string) bool {
+	if id == "" || len(id) > maxLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
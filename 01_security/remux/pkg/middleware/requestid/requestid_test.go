@@ -0,0 +1,77 @@
+package requestid
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRequestID(t *testing.T) {
+	var got string
+	handler := RequestID(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		id, err := RequestIDFrom(request.Context())
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = id
+	}))
+
+	tests := []struct {
+		name     string
+		header   string
+		generate bool
+	}{
+		{name: "accepted", header: "abc-123", generate: false},
+		{name: "missing", header: "", generate: true},
+		{name: "control chars", header: "abc\n123", generate: true},
+		{name: "spaces", header: "abc 123", generate: true},
+		{name: "too long", header: strings.Repeat("a", maxLength+1), generate: true},
+	}
+
+	for _, tt := range tests {
+		request := httptest.NewRequest(http.MethodGet, "/", nil)
+		if tt.header != "" {
+			request.Header.Set(Header, tt.header)
+		}
+		response := httptest.NewRecorder()
+		handler.ServeHTTP(response, request)
+
+		if tt.generate && (got == "" || got == tt.header) {
+			t.Errorf("%s: got %q, want generated id", tt.name, got)
+		}
+		if !tt.generate && got != tt.header {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.header)
+		}
+		if echoed := response.Header().Get(Header); echoed != got {
+			t.Errorf("%s: response header %q, want %q", tt.name, echoed, got)
+		}
+	}
+}
+
+func TestTransport(t *testing.T) {
+	var got string
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		got = request.Header.Get(Header)
+	}))
+	defer server.Close()
+
+	client := &http.Client{Transport: &Transport{}}
+	request, err := http.NewRequestWithContext(WithRequestID(context.Background(), "abc-123"), http.MethodGet, server.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	response, err := client.Do(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	response.Body.Close()
+
+	if got != "abc-123" {
+		t.Errorf("got %q, want %q", got, "abc-123")
+	}
+	if request.Header.Get(Header) != "" {
+		t.Error("original request must not be modified")
+	}
+}
@@ -0,0 +1,78 @@
+package remux
+
+import "net/http"
+
+// Chain - цепочка middleware, которые выполняются в порядке объявления:
+// NewChain(a, b).Then(h) вызовет сначала a, затем b, затем h.
+// Chain неизменяем: Append и Prepend возвращают новую цепочку.
+type Chain struct {
+	middlewares []Middleware
+}
+
+func NewChain(middlewares ...Middleware) Chain {
+	return Chain{middlewares: append([]Middleware(nil), middlewares...)}
+}
+
+// Append возвращает цепочку, в которой middlewares выполняются после текущих
+func (c Chain) Append(middlewares ...Middleware) Chain {
+	result := make([]Middleware, 0, len(c.middlewares)+len(middlewares))
+	result = append(result, c.middlewares...)
+	return Chain{middlewares: append(result, middlewares...)}
+}
+
+// Prepend возвращает цепочку, в которой middlewares выполняются до текущих
+func (c Chain) Prepend(middlewares ...Middleware) Chain {
+	result := make([]Middleware, 0, len(c.middlewares)+len(middlewares))
+	result = append(result, middlewares...)
+	return Chain{middlewares: append(result, c.middlewares...)}
+}
+
+// Extend возвращает цепочку, в которой после текущих middleware выполняются middleware другой цепочки
+func (c Chain) Extend(chain Chain) Chain {
+	return c.Append(chain.middlewares...)
+}
+
+// Then оборачивает handler всеми middleware цепочки
+func (c Chain) Then(handler http.Handler) http.Handler {
+	for i := len(c.middlewares) - 1; i >= 0; i-- {
+		handler = c.middlewares[i](handler)
+	}
+	return handler
+}
+
+func (c Chain) ThenFunc(handler http.HandlerFunc) http.Handler {
+	return c.Then(handler)
+}
+
+// Order - порядок выполнения middleware, переданных в RegisterPlain, RegisterRegex, RegisterPattern, Group и Use
+type Order int
+
+const (
+	// OrderReverse - исторический порядок: последний в списке middleware выполняется первым
+	OrderReverse Order = iota
+	// OrderDeclaration - middleware выполняются в порядке объявления (как в Chain)
+	OrderDeclaration
+)
+
+type Option func(mux *ReMux)
+
+// WithOrder задаёт порядок выполнения middleware. По умолчанию используется OrderReverse,
+// чтобы не менять поведение существующих регистраций.
+func WithOrder(order Order) Option {
+	return func(mux *ReMux) {
+		mux.order = order
+	}
+}
+
+// chain переводит список middleware в Chain с учётом порядка, заданного для ReMux
+func (r *ReMux) chain(middlewares ...Middleware) Chain {
+	if r.order == OrderDeclaration {
+		return NewChain(middlewares...)
+	}
+
+	reversed := make([]Middleware, 0, len(middlewares))
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		reversed = append(reversed, middlewares[i])
+	}
+	return Chain{middlewares: reversed}
+}
@@ -0,0 +1,79 @@
+package remux
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func traceMd(name string) Middleware {
+	return func(handler http.Handler) http.Handler {
+		return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+			writer.Write([]byte(name + ">"))
+			handler.ServeHTTP(writer, request)
+		})
+	}
+}
+
+func TestChain(t *testing.T) {
+	handler := http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		writer.Write([]byte("handler"))
+	})
+	base := NewChain(traceMd("b"), traceMd("c"))
+
+	tests := []struct {
+		name  string
+		chain Chain
+		want  []byte
+	}{
+		{name: "declaration order", chain: base, want: []byte("b>c>handler")},
+		{name: "append", chain: base.Append(traceMd("d")), want: []byte("b>c>d>handler")},
+		{name: "prepend", chain: base.Prepend(traceMd("a")), want: []byte("a>b>c>handler")},
+		{name: "immutable", chain: base, want: []byte("b>c>handler")},
+	}
+
+	for _, tt := range tests {
+		response := httptest.NewRecorder()
+		tt.chain.Then(handler).ServeHTTP(response, httptest.NewRequest(http.MethodGet, "/", nil))
+		got := response.Body.Bytes()
+		if !bytes.Equal(tt.want, got) {
+			t.Errorf("%s: got %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestReMux_Order(t *testing.T) {
+	handler := http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		writer.Write([]byte("handler"))
+	})
+
+	tests := []struct {
+		name  string
+		order Order
+		want  []byte
+	}{
+		{name: "reverse", order: OrderReverse, want: []byte("use2>use1>group2>group1>route2>route1>handler")},
+		{name: "declaration", order: OrderDeclaration, want: []byte("use1>use2>group1>group2>route1>route2>handler")},
+	}
+
+	for _, tt := range tests {
+		mux := NewReMux(WithOrder(tt.order))
+		mux.Use(traceMd("use1"))
+		mux.Use(traceMd("use2"))
+		group, err := mux.Group("/api", traceMd("group1"), traceMd("group2"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := group.RegisterPlain(GET, "/get", handler, traceMd("route1"), traceMd("route2")); err != nil {
+			t.Fatal(err)
+		}
+
+		response := httptest.NewRecorder()
+		mux.ServeHTTP(response, httptest.NewRequest(http.MethodGet, "/api/get", nil))
+		got := response.Body.Bytes()
+		if !bytes.Equal(tt.want, got) {
+			t.Errorf("%s: got %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,210 @@
+package remux
+
+import (
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// Group - набор маршрутов с общим префиксом, общими middleware и общими условиями выбора (Matcher).
+// Маршруты регистрируются в родительском ReMux, middleware группы выполняются раньше middleware маршрута.
+type Group struct {
+	mux      *ReMux
+	prefix   string
+	chain    Chain
+	matchers []Matcher
+}
+
+// Group создаёт группу маршрутов с префиксом prefix (пустой или начинающийся с /)
+func (r *ReMux) Group(prefix string, middlewares ...Middleware) (*Group, error) {
+	prefix, err := cleanPrefix(prefix)
+	if err != nil {
+		return nil, err
+	}
+	return &Group{mux: r, prefix: prefix, chain: r.chain(middlewares...)}, nil
+}
+
+// Group создаёт вложенную группу: префиксы склеиваются, middleware родительской группы выполняются первыми
+func (g *Group) Group(prefix string, middlewares ...Middleware) (*Group, error) {
+	prefix, err := cleanPrefix(prefix)
+	if err != nil {
+		return nil, err
+	}
+	return &Group{
+		mux:      g.mux,
+		prefix:   g.prefix + prefix,
+		chain:    g.with(middlewares...),
+		matchers: g.matchers,
+	}, nil
+}
+
+// When возвращает группу, маршруты которой выбираются только при выполнении всех условий:
+//
+//	mux.When(remux.Host("api.example.com"), remux.Header("Accept-Version", "2")).RegisterPlain(...)
+//
+// Условия проверяются после совпадения пути. Один путь можно зарегистрировать несколько раз с разными условиями.
+func (r *ReMux) When(matchers ...Matcher) *Group {
+	return &Group{mux: r, matchers: matchers}
+}
+
+// When возвращает копию группы с дополнительными условиями выбора маршрутов
+func (g *Group) When(matchers ...Matcher) *Group {
+	combined := make([]Matcher, 0, len(g.matchers)+len(matchers))
+	combined = append(combined, g.matchers...)
+	return &Group{
+		mux:      g.mux,
+		prefix:   g.prefix,
+		chain:    g.chain,
+		matchers: append(combined, matchers...),
+	}
+}
+
+func (g *Group) RegisterPlain(method Method, path string, handler http.Handler, middlewares ...Middleware) error {
+	if !strings.HasPrefix(path, "/") {
+		return ErrInvalidPath
+	}
+	return g.mux.registerPlain(method, g.prefix+path, handler, g.with(middlewares...), g.matchers)
+}
+
+func (g *Group) RegisterPattern(method Method, pattern string, handler http.Handler, middlewares ...Middleware) error {
+	if !strings.HasPrefix(pattern, "/") {
+		return ErrInvalidPath
+	}
+	return g.mux.registerPattern("", method, g.prefix+pattern, handler, g.with(middlewares...), g.matchers)
+}
+
+func (g *Group) RegisterNamed(name string, method Method, pattern string, handler http.Handler, middlewares ...Middleware) error {
+	if name == "" {
+		return ErrInvalidName
+	}
+	if !strings.HasPrefix(pattern, "/") {
+		return ErrInvalidPath
+	}
+	return g.mux.registerPattern(name, method, g.prefix+pattern, handler, g.with(middlewares...), g.matchers)
+}
+
+// RegisterRegex добавляет префикс группы сразу после ^ в выражении
+func (g *Group) RegisterRegex(method Method, path *regexp.Regexp, handler http.Handler, middlewares ...Middleware) error {
+	if !strings.HasPrefix(path.String(), `^/`) {
+		return ErrInvalidPath
+	}
+	prefixed, err := regexp.Compile(`^` + regexp.QuoteMeta(g.prefix) + strings.TrimPrefix(path.String(), `^`))
+	if err != nil {
+		return ErrInvalidPath
+	}
+	return g.mux.registerRegex(method, prefixed, handler, g.with(middlewares...), g.matchers)
+}
+
+func (g *Group) Mount(prefix string, handler http.Handler, middlewares ...Middleware) error {
+	prefix, err := cleanPrefix(prefix)
+	if err != nil {
+		return err
+	}
+	return g.mux.mount(g.prefix+prefix, handler, g.with(middlewares...), g.matchers)
+}
+
+// Mount передаёт все запросы с префиксом prefix (любым методом) обработчику handler, например, другому ReMux.
+// Префикс из пути удаляется, как в http.StripPrefix.
+func (r *ReMux) Mount(prefix string, handler http.Handler, middlewares ...Middleware) error {
+	prefix, err := cleanPrefix(prefix)
+	if err != nil {
+		return err
+	}
+	return r.mount(prefix, handler, r.chain(middlewares...), nil)
+}
+
+func (r *ReMux) mount(prefix string, handler http.Handler, chain Chain, matchers []Matcher) error {
+	if handler == nil {
+		return ErrNilHandler
+	}
+	segments, err := parsePattern(prefix + "/")
+	if err != nil {
+		return err
+	}
+	for _, seg := range segments {
+		if seg.kind != kindStatic {
+			return ErrInvalidPath
+		}
+	}
+
+	patterns := []string{prefix + "/", prefix + "/{path...}"}
+	if prefix != "" {
+		patterns = append(patterns, prefix)
+	}
+	parsed := make([][]segment, 0, len(patterns))
+	for _, pattern := range patterns {
+		segments, err := parsePattern(pattern)
+		if err != nil {
+			return err
+		}
+		parsed = append(parsed, segments)
+	}
+
+	stripped := chain.Then(stripPrefix(prefix, handler))
+	// все маршруты добавляются одним обновлением: при конфликте таблица остаётся прежней
+	return r.update(func(t *table) error {
+		for _, method := range methods {
+			for i, pattern := range patterns {
+				err := t.insertPattern("", method, pattern, parsed[i], &endpoint{handler: stripped, chain: chain, matchers: matchers})
+				if err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// stripPrefix работает как http.StripPrefix, но вместо пустого пути передаёт дальше /
+func stripPrefix(prefix string, handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		path := "/" + strings.TrimPrefix(strings.TrimPrefix(request.URL.Path, prefix), "/")
+		rawPath := ""
+		if request.URL.RawPath != "" {
+			rawPath = "/" + strings.TrimPrefix(strings.TrimPrefix(request.URL.RawPath, prefix), "/")
+		}
+
+		req := request.Clone(request.Context())
+		req.URL.Path = path
+		req.URL.RawPath = rawPath
+		handler.ServeHTTP(writer, req)
+	})
+}
+
+// Use добавляет middleware, которые применяются ко всем маршрутам (в том числе зарегистрированным ранее),
+// а также к обработчикам NotFound и MethodNotAllowed
+func (r *ReMux) Use(middlewares ...Middleware) {
+	_ = r.update(func(t *table) error {
+		if r.order == OrderDeclaration {
+			t.middlewares = t.middlewares.Extend(r.chain(middlewares...))
+			return nil
+		}
+		// в историческом порядке добавленные позже middleware выполняются раньше
+		t.middlewares = r.chain(middlewares...).Extend(t.middlewares)
+		return nil
+	})
+}
+
+// ServeHTTP обрабатывает запрос маршрутами родительского ReMux, добавляя к пути префикс группы,
+// поэтому группу целиком можно смонтировать в другой ReMux:
+//
+//	other.Mount("/v1", api)  // /v1/items обработает маршрут группы /api/items
+func (g *Group) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	if g.prefix == "" {
+		g.mux.ServeHTTP(writer, request)
+		return
+	}
+	g.mux.ServeHTTP(writer, withPath(request, g.prefix+request.URL.EscapedPath()))
+}
+
+// with возвращает цепочку группы, дополненную middleware маршрута (middleware группы выполняются первыми)
+func (g *Group) with(middlewares ...Middleware) Chain {
+	return g.chain.Extend(g.mux.chain(middlewares...))
+}
+
+func cleanPrefix(prefix string) (string, error) {
+	if prefix != "" && !strings.HasPrefix(prefix, "/") {
+		return "", ErrInvalidPath
+	}
+	return strings.TrimSuffix(prefix, "/"), nil
+}
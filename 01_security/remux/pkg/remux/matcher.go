@@ -0,0 +1,188 @@
+package remux
+
+import (
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// Matcher - дополнительное условие выбора маршрута, проверяемое после совпадения пути.
+// Match может добавить значения в params (например, Host захватывает параметры из имени хоста).
+type Matcher interface {
+	Match(request *http.Request, params *Params) bool
+	String() string
+}
+
+type hostMatcher struct {
+	pattern string
+	labels  []string // метки хоста, {name} - параметр
+}
+
+// Host проверяет имя хоста запроса (без порта, без учёта регистра).
+// Метка вида {name} совпадает с любой непустой меткой и попадает в Params.Named:
+//
+//	remux.Host("{tenant}.example.com")
+func Host(pattern string) Matcher {
+	pattern = strings.ToLower(pattern)
+	return &hostMatcher{pattern: pattern, labels: strings.Split(pattern, ".")}
+}
+
+func (m *hostMatcher) Match(request *http.Request, params *Params) bool {
+	host := strings.ToLower(request.Host)
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+
+	labels := strings.Split(host, ".")
+	if len(labels) != len(m.labels) {
+		return false
+	}
+
+	captured := make(map[string]string)
+	for index, label := range m.labels {
+		if isParamLabel(label) {
+			if labels[index] == "" {
+				return false
+			}
+			captured[label[1:len(label)-1]] = labels[index]
+			continue
+		}
+		if label != labels[index] {
+			return false
+		}
+	}
+
+	for name, value := range captured {
+		params.Named[name] = value
+	}
+	return true
+}
+
+func (m *hostMatcher) String() string {
+	return "Host(" + m.pattern + ")"
+}
+
+type headerMatcher struct {
+	name  string
+	value string
+}
+
+// Header проверяет, что заголовок name содержит значение value (при пустом value - что заголовок присутствует)
+func Header(name string, value string) Matcher {
+	return &headerMatcher{name: http.CanonicalHeaderKey(name), value: value}
+}
+
+func (m *headerMatcher) Match(request *http.Request, params *Params) bool {
+	return containsValue(request.Header[m.name], m.value)
+}
+
+func (m *headerMatcher) String() string {
+	return "Header(" + m.name + "=" + m.value + ")"
+}
+
+type queryMatcher struct {
+	name  string
+	value string
+}
+
+// Query проверяет, что параметр строки запроса name имеет значение value (при пустом value - что параметр присутствует)
+func Query(name string, value string) Matcher {
+	return &queryMatcher{name: name, value: value}
+}
+
+func (m *queryMatcher) Match(request *http.Request, params *Params) bool {
+	return containsValue(request.URL.Query()[m.name], m.value)
+}
+
+func (m *queryMatcher) String() string {
+	return "Query(" + m.name + "=" + m.value + ")"
+}
+
+// endpoints - обработчики одного пути, различающиеся условиями (Matcher).
+// Упорядочены от более специфичных (больше условий) к менее специфичным, при равенстве - по порядку регистрации.
+// Срез не изменяется после публикации таблицы: add возвращает новый срез.
+type endpoints []*endpoint
+
+func (e endpoints) add(endpoint *endpoint) (endpoints, error) {
+	key := matchersKey(endpoint.matchers)
+	for _, existing := range e {
+		if matchersKey(existing.matchers) == key {
+			return nil, ErrAmbiguousMapping
+		}
+	}
+
+	result := make(endpoints, 0, len(e)+1)
+	result = append(result, e...)
+	result = append(result, endpoint)
+	sort.SliceStable(result, func(i, j int) bool {
+		return len(result[i].matchers) > len(result[j].matchers)
+	})
+	return result, nil
+}
+
+// match возвращает первый обработчик, все условия которого выполнены, и params, дополненные условиями
+func (e endpoints) match(request *http.Request, params *Params) (*endpoint, *Params) {
+	for _, endpoint := range e {
+		if len(endpoint.matchers) == 0 {
+			return endpoint, params
+		}
+
+		candidate := params.clone()
+		matched := true
+		for _, matcher := range endpoint.matchers {
+			if !matcher.Match(request, candidate) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return endpoint, candidate
+		}
+	}
+	return nil, nil
+}
+
+func (p *Params) clone() *Params {
+	result := &Params{
+		Named:      make(map[string]string, len(p.Named)),
+		Positional: p.Positional,
+	}
+	for name, value := range p.Named {
+		result.Named[name] = value
+	}
+	return result
+}
+
+func matchersKey(matchers []Matcher) string {
+	keys := make([]string, 0, len(matchers))
+	for _, matcher := range matchers {
+		keys = append(keys, matcher.String())
+	}
+	sort.Strings(keys)
+	return strings.Join(keys, "&")
+}
+
+func matchersNames(matchers []Matcher) []string {
+	names := make([]string, 0, len(matchers))
+	for _, matcher := range matchers {
+		names = append(names, matcher.String())
+	}
+	return names
+}
+
+func isParamLabel(label string) bool {
+	return len(label) > 2 && strings.HasPrefix(label, "{") && strings.HasSuffix(label, "}")
+}
+
+func containsValue(values []string, value string) bool {
+	if value == "" {
+		return len(values) != 0
+	}
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,74 @@
+package remux
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestReMux_Matchers(t *testing.T) {
+	mux := NewReMux()
+	respond := func(name string) http.Handler {
+		return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+			writer.Write([]byte(name))
+			if params, err := PathParams(request.Context()); err == nil {
+				writer.Write([]byte(" " + params.Named["tenant"]))
+			}
+		})
+	}
+	if err := mux.RegisterPlain(GET, "/report", respond("default")); err != nil {
+		t.Fatal(err)
+	}
+	if err := mux.When(Query("format", "csv")).RegisterPlain(GET, "/report", respond("csv")); err != nil {
+		t.Fatal(err)
+	}
+	if err := mux.When(Header("Accept-Version", "2")).RegisterPlain(GET, "/report", respond("v2")); err != nil {
+		t.Fatal(err)
+	}
+	if err := mux.When(Header("accept-version", "2")).RegisterPlain(GET, "/report", respond("dup")); err != ErrAmbiguousMapping {
+		t.Errorf("got %v, want %v", err, ErrAmbiguousMapping)
+	}
+	tenant := mux.When(Host("{tenant}.example.com"))
+	if err := tenant.RegisterPattern(GET, "/users/{id}", respond("tenant")); err != nil {
+		t.Fatal(err)
+	}
+	if err := tenant.When(Header("Accept-Version", "2")).RegisterPattern(GET, "/users/{id}", respond("tenant v2")); err != nil {
+		t.Fatal(err)
+	}
+
+	type args struct {
+		path   string
+		host   string
+		header string
+	}
+
+	tests := []struct {
+		name string
+		args args
+		want []byte
+	}{
+		{name: "default", args: args{path: "/report"}, want: []byte("default")},
+		{name: "query", args: args{path: "/report?format=csv"}, want: []byte("csv")},
+		{name: "header", args: args{path: "/report", header: "2"}, want: []byte("v2")},
+		{name: "host", args: args{path: "/users/1", host: "acme.example.com:8080"}, want: []byte("tenant acme")},
+		{name: "host and header", args: args{path: "/users/1", host: "acme.example.com", header: "2"}, want: []byte("tenant v2 acme")},
+		{name: "other host", args: args{path: "/users/1", host: "example.com"}, want: []byte{}},
+	}
+
+	for _, tt := range tests {
+		request := httptest.NewRequest(http.MethodGet, tt.args.path, nil)
+		if tt.args.host != "" {
+			request.Host = tt.args.host
+		}
+		if tt.args.header != "" {
+			request.Header.Set("Accept-Version", tt.args.header)
+		}
+		response := httptest.NewRecorder()
+		mux.ServeHTTP(response, request)
+		got := response.Body.Bytes()
+		if !bytes.Equal(tt.want, got) {
+			t.Errorf("%s: got %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,236 @@
+package remux
+
+import (
+	"context"
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrParamNotFound     = errors.New("param not found")
+	ErrInvalidBindTarget = errors.New("bind target must be a pointer to struct")
+)
+
+// ParamError - ошибка получения или преобразования параметра пути
+type ParamError struct {
+	Name  string
+	Value string
+	Err   error
+}
+
+func (e *ParamError) Error() string {
+	return fmt.Sprintf("invalid param %s: %v", e.Name, e.Err)
+}
+
+func (e *ParamError) Unwrap() error {
+	return e.Err
+}
+
+// ParamErrors - все ошибки, найденные Bind
+type ParamErrors []*ParamError
+
+func (e ParamErrors) Error() string {
+	messages := make([]string, 0, len(e))
+	for _, err := range e {
+		messages = append(messages, err.Error())
+	}
+	return strings.Join(messages, "; ")
+}
+
+// Param возвращает именованный параметр пути
+func Param(ctx context.Context, name string) (string, error) {
+	params, err := PathParams(ctx)
+	if err != nil {
+		return "", err
+	}
+	value, ok := params.Named[name]
+	if !ok {
+		return "", &ParamError{Name: name, Err: ErrParamNotFound}
+	}
+	return value, nil
+}
+
+func ParamInt64(ctx context.Context, name string) (int64, error) {
+	value, err := Param(ctx, name)
+	if err != nil {
+		return 0, err
+	}
+	parsed, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, &ParamError{Name: name, Value: value, Err: err}
+	}
+	return parsed, nil
+}
+
+func ParamInt(ctx context.Context, name string) (int, error) {
+	value, err := Param(ctx, name)
+	if err != nil {
+		return 0, err
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, &ParamError{Name: name, Value: value, Err: err}
+	}
+	return parsed, nil
+}
+
+func ParamUUID(ctx context.Context, name string) (uuid.UUID, error) {
+	value, err := Param(ctx, name)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	parsed, err := uuid.Parse(value)
+	if err != nil {
+		return uuid.Nil, &ParamError{Name: name, Value: value, Err: err}
+	}
+	return parsed, nil
+}
+
+// Bind заполняет поля структуры, помеченные тегом path, значениями параметров пути:
+//
+//	var params struct {
+//		ID    int64  `path:"id"`
+//		Order string `path:"orderID,optional"`
+//	}
+//	err := remux.Bind(request.Context(), &params)
+//
+// Поддерживаются строки, целые и вещественные числа, bool и типы, реализующие encoding.TextUnmarshaler (например, uuid.UUID).
+// Ошибки по отдельным полям возвращаются вместе в ParamErrors.
+func Bind(ctx context.Context, dest interface{}) error {
+	value := reflect.ValueOf(dest)
+	if value.Kind() != reflect.Ptr || value.IsNil() || value.Elem().Kind() != reflect.Struct {
+		return ErrInvalidBindTarget
+	}
+
+	params, err := PathParams(ctx)
+	if err != nil {
+		return err
+	}
+
+	errs := make(ParamErrors, 0)
+	value = value.Elem()
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Type().Field(i)
+		tag, ok := field.Tag.Lookup("path")
+		if !ok || tag == "-" {
+			continue
+		}
+		name, optional := tag, false
+		if comma := strings.IndexByte(tag, ','); comma != -1 {
+			name, optional = tag[:comma], tag[comma+1:] == "optional"
+		}
+
+		raw, ok := params.Named[name]
+		if !ok {
+			if !optional {
+				errs = append(errs, &ParamError{Name: name, Err: ErrParamNotFound})
+			}
+			continue
+		}
+
+		if err := setField(value.Field(i), raw); err != nil {
+			errs = append(errs, &ParamError{Name: name, Value: raw, Err: err})
+		}
+	}
+
+	if len(errs) != 0 {
+		return errs
+	}
+	return nil
+}
+
+var textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+
+func setField(field reflect.Value, raw string) error {
+	if !field.CanSet() {
+		return ErrInvalidBindTarget
+	}
+
+	if reflect.PtrTo(field.Type()).Implements(textUnmarshalerType) {
+		return field.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(raw))
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(raw)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		parsed, err := strconv.ParseInt(raw, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetInt(parsed)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		parsed, err := strconv.ParseUint(raw, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetUint(parsed)
+	case reflect.Float32, reflect.Float64:
+		parsed, err := strconv.ParseFloat(raw, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetFloat(parsed)
+	case reflect.Bool:
+		parsed, err := strconv.ParseBool(raw)
+		if err != nil {
+			return err
+		}
+		field.SetBool(parsed)
+	default:
+		return fmt.Errorf("unsupported field type %s", field.Type())
+	}
+	return nil
+}
+
+// problem - тело ответа об ошибке в формате RFC 7807 (application/problem+json)
+type problem struct {
+	Type          string          `json:"type"`
+	Title         string          `json:"title"`
+	Status        int             `json:"status"`
+	Detail        string          `json:"detail,omitempty"`
+	InvalidParams []*invalidParam `json:"invalid-params,omitempty"`
+}
+
+type invalidParam struct {
+	Name   string `json:"name"`
+	Reason string `json:"reason"`
+}
+
+// WriteParamError отвечает 400 Bad Request с телом application/problem+json,
+// перечисляя параметры из ParamError/ParamErrors
+func WriteParamError(writer http.ResponseWriter, err error) {
+	body := &problem{
+		Type:   "about:blank",
+		Title:  http.StatusText(http.StatusBadRequest),
+		Status: http.StatusBadRequest,
+		Detail: err.Error(),
+	}
+
+	var paramErrs ParamErrors
+	var paramErr *ParamError
+	if errors.As(err, &paramErrs) {
+		for _, e := range paramErrs {
+			body.InvalidParams = append(body.InvalidParams, &invalidParam{Name: e.Name, Reason: e.Err.Error()})
+		}
+	} else if errors.As(err, &paramErr) {
+		body.InvalidParams = append(body.InvalidParams, &invalidParam{Name: paramErr.Name, Reason: paramErr.Err.Error()})
+	}
+
+	data, err := json.Marshal(body)
+	if err != nil {
+		writer.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	writer.Header().Set("Content-Type", "application/problem+json")
+	writer.WriteHeader(http.StatusBadRequest)
+	_, _ = writer.Write(data)
+}
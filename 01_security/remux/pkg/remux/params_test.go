@@ -0,0 +1,90 @@
+package remux
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/google/uuid"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestParams(t *testing.T) {
+	mux := NewReMux()
+	if err := mux.RegisterPattern(GET, "/users/{id}/orders/{orderID}", http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		id, err := ParamInt64(request.Context(), "id")
+		if err != nil {
+			WriteParamError(writer, err)
+			return
+		}
+		orderID, err := ParamUUID(request.Context(), "orderID")
+		if err != nil {
+			WriteParamError(writer, err)
+			return
+		}
+
+		var params struct {
+			ID      int64     `path:"id"`
+			OrderID uuid.UUID `path:"orderID"`
+			Page    int       `path:"page,optional"`
+		}
+		if err := Bind(request.Context(), &params); err != nil {
+			t.Error(err)
+		}
+		if params.ID != id || params.OrderID != orderID {
+			t.Errorf("got %v, want %v %v", params, id, orderID)
+		}
+	})); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		path        string
+		want        int
+		wantInvalid string
+	}{
+		{name: "valid", path: "/users/1/orders/5f0c8d3e-9a6b-4c1d-8e2f-3a4b5c6d7e8f", want: http.StatusOK},
+		{name: "invalid int", path: "/users/me/orders/5f0c8d3e-9a6b-4c1d-8e2f-3a4b5c6d7e8f", want: http.StatusBadRequest, wantInvalid: "id"},
+		{name: "invalid uuid", path: "/users/1/orders/2", want: http.StatusBadRequest, wantInvalid: "orderID"},
+	}
+
+	for _, tt := range tests {
+		response := httptest.NewRecorder()
+		mux.ServeHTTP(response, httptest.NewRequest(http.MethodGet, tt.path, nil))
+		if got := response.Code; got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+		if tt.wantInvalid == "" {
+			continue
+		}
+		body := &problem{}
+		if err := json.Unmarshal(response.Body.Bytes(), body); err != nil {
+			t.Fatal(err)
+		}
+		if len(body.InvalidParams) != 1 || body.InvalidParams[0].Name != tt.wantInvalid {
+			t.Errorf("%s: got %v, want %s", tt.name, body.InvalidParams, tt.wantInvalid)
+		}
+	}
+}
+
+func TestBind(t *testing.T) {
+	ctx := context.Background()
+	var params struct {
+		ID int64 `path:"id"`
+	}
+	if err := Bind(ctx, &params); err != ErrNoParams {
+		t.Errorf("got %v, want %v", err, ErrNoParams)
+	}
+	if err := Bind(ctx, params); err != ErrInvalidBindTarget {
+		t.Errorf("got %v, want %v", err, ErrInvalidBindTarget)
+	}
+
+	ctx = context.WithValue(ctx, paramsContextKey, &Params{Named: map[string]string{}})
+	err := Bind(ctx, &params)
+	var paramErrs ParamErrors
+	if !errors.As(err, &paramErrs) || len(paramErrs) != 1 || !errors.Is(paramErrs[0], ErrParamNotFound) {
+		t.Errorf("got %v, want %v", err, ErrParamNotFound)
+	}
+}
@@ -0,0 +1,115 @@
+package remux
+
+import (
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// PathPolicy определяет, что делать с запросом, путь которого не совпал ни с одним маршрутом,
+// но совпал бы после очистки (//, ., ..) или после добавления/удаления завершающего /
+type PathPolicy int
+
+const (
+	// PathStrict - путь используется как есть (поведение по умолчанию)
+	PathStrict PathPolicy = iota
+	// PathClean - запрос прозрачно обслуживается маршрутом для канонического пути
+	PathClean
+	// PathRedirect - клиент перенаправляется на канонический путь: 301 для GET и HEAD, 308 для остальных методов
+	PathRedirect
+)
+
+// WithPathPolicy задаёт политику обработки неканонических путей
+func WithPathPolicy(policy PathPolicy) Option {
+	return func(mux *ReMux) {
+		mux.pathPolicy = policy
+	}
+}
+
+// canonical возвращает экранированный канонический путь, если для исходного пути маршрута нет, а для канонического - есть
+func (t *table) canonical(method Method, request *http.Request) (string, bool) {
+	if t.exists(method, request) {
+		return "", false
+	}
+
+	escaped := request.URL.EscapedPath()
+	cleaned := cleanPath(escaped)
+	for _, candidate := range []string{cleaned, toggleSlash(cleaned)} {
+		if candidate == escaped || candidate == "" {
+			continue
+		}
+		if t.exists(method, withPath(request, candidate)) {
+			return candidate, true
+		}
+	}
+	return "", false
+}
+
+// exists проверяет, есть ли маршрут для метода (HEAD обслуживается GET-маршрутом)
+func (t *table) exists(method Method, request *http.Request) bool {
+	if handler, _ := t.match(method, request); handler != nil {
+		return true
+	}
+	if method == HEAD {
+		if handler, _ := t.match(GET, request); handler != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// redirect перенаправляет на путь escaped, сохраняя строку запроса
+func redirect(writer http.ResponseWriter, request *http.Request, escaped string) {
+	location := escaped
+	if request.URL.RawQuery != "" {
+		location += "?" + request.URL.RawQuery
+	}
+
+	code := http.StatusPermanentRedirect
+	if request.Method == http.MethodGet || request.Method == http.MethodHead {
+		code = http.StatusMovedPermanently
+	}
+	http.Redirect(writer, request, location, code)
+}
+
+// withPath возвращает копию запроса с путём escaped (в экранированной форме)
+func withPath(request *http.Request, escaped string) *http.Request {
+	unescaped, err := url.PathUnescape(escaped)
+	if err != nil {
+		return request
+	}
+
+	result := request.Clone(request.Context())
+	result.URL.Path = unescaped
+	result.URL.RawPath = ""
+	if result.URL.EscapedPath() != escaped {
+		result.URL.RawPath = escaped
+	}
+	return result
+}
+
+// cleanPath работает как path.Clean, но сохраняет завершающий /
+func cleanPath(p string) string {
+	if p == "" {
+		return "/"
+	}
+	if p[0] != '/' {
+		p = "/" + p
+	}
+	cleaned := path.Clean(p)
+	if strings.HasSuffix(p, "/") && cleaned != "/" {
+		cleaned += "/"
+	}
+	return cleaned
+}
+
+func toggleSlash(p string) string {
+	if p == "/" {
+		return ""
+	}
+	if strings.HasSuffix(p, "/") {
+		return strings.TrimSuffix(p, "/")
+	}
+	return p + "/"
+}
@@ -0,0 +1,77 @@
+package remux
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestReMux_PathPolicy(t *testing.T) {
+	handler := http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		writer.Write([]byte(request.URL.Path))
+	})
+
+	type args struct {
+		policy PathPolicy
+		method Method
+		path   string
+	}
+
+	tests := []struct {
+		name         string
+		args         args
+		want         int
+		wantLocation string
+		wantBody     string
+	}{
+		{name: "strict", args: args{policy: PathStrict, method: GET, path: "/admin/"}, want: http.StatusNotFound},
+		{name: "strict double slash", args: args{policy: PathStrict, method: GET, path: "//admin"}, want: http.StatusNotFound},
+		{name: "clean trailing slash", args: args{policy: PathClean, method: GET, path: "/admin/"}, want: http.StatusOK, wantBody: "/admin"},
+		{name: "clean dot dot", args: args{policy: PathClean, method: GET, path: "/users/../admin"}, want: http.StatusOK, wantBody: "/admin"},
+		{name: "clean add slash", args: args{policy: PathClean, method: POST, path: "/dir"}, want: http.StatusOK, wantBody: "/dir/"},
+		{name: "redirect GET", args: args{policy: PathRedirect, method: GET, path: "//admin?page=1"}, want: http.StatusMovedPermanently, wantLocation: "/admin?page=1"},
+		{name: "redirect POST", args: args{policy: PathRedirect, method: POST, path: "/dir"}, want: http.StatusPermanentRedirect, wantLocation: "/dir/"},
+		{name: "redirect not found", args: args{policy: PathRedirect, method: GET, path: "/other/"}, want: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		mux := NewReMux(WithPathPolicy(tt.args.policy))
+		if err := mux.RegisterPlain(GET, "/admin", handler); err != nil {
+			t.Fatal(err)
+		}
+		if err := mux.RegisterPlain(POST, "/dir/", handler); err != nil {
+			t.Fatal(err)
+		}
+
+		response := httptest.NewRecorder()
+		mux.ServeHTTP(response, httptest.NewRequest(string(tt.args.method), tt.args.path, nil))
+		if got := response.Code; got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+		if got := response.Header().Get("Location"); got != tt.wantLocation {
+			t.Errorf("%s: got %s, want %s", tt.name, got, tt.wantLocation)
+		}
+		if tt.wantBody != "" && response.Body.String() != tt.wantBody {
+			t.Errorf("%s: got %s, want %s", tt.name, response.Body.String(), tt.wantBody)
+		}
+	}
+}
+
+func TestReMux_EscapedPath(t *testing.T) {
+	mux := NewReMux()
+	if err := mux.RegisterPattern(GET, "/files/{name}", http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		name, err := Param(request.Context(), "name")
+		if err != nil {
+			t.Error(err)
+		}
+		writer.Write([]byte(name))
+	})); err != nil {
+		t.Fatal(err)
+	}
+
+	response := httptest.NewRecorder()
+	mux.ServeHTTP(response, httptest.NewRequest(http.MethodGet, "/files/docs%2Freadme.md", nil))
+	if got := response.Body.String(); got != "docs/readme.md" {
+		t.Errorf("got %s, want %s", got, "docs/readme.md")
+	}
+}
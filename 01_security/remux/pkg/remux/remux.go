@@ -7,9 +7,11 @@ import (
 	"regexp"
 	"strings"
 	"sync"
+	"sync/atomic"
 )
 
 var paramsContextKey = &contextKey{"remux context"}
+var routeContextKey = &contextKey{"remux route context"}
 
 type contextKey struct {
 	name string
@@ -24,21 +26,47 @@ type Params struct {
 	Positional []string
 }
 
+// ReMux хранит маршруты в неизменяемом снимке table, который подменяется атомарно (см. update),
+// поэтому ServeHTTP не берёт блокировок. mu только упорядочивает изменения таблицы.
 type ReMux struct {
-	mu              sync.RWMutex
-	plain           map[Method]map[string]http.Handler
-	regex           map[Method]map[*regexp.Regexp]http.Handler
-	notFoundHandler http.Handler
+	mu         sync.Mutex
+	table      atomic.Value // *table
+	order      Order
+	pathPolicy PathPolicy
+}
+
+// endpoint - обработчик (уже обёрнутый в middleware), условия его выбора и цепочка middleware для интроспекции
+type endpoint struct {
+	handler  http.Handler
+	chain    Chain
+	matchers []Matcher
+}
+
+// regexRoute хранит regex-маршруты в порядке регистрации, чтобы выбор обработчика был детерминированным
+type regexRoute struct {
+	regex     *regexp.Regexp
+	endpoints endpoints
 }
 
 var defaultNotFoundHandler = func(writer http.ResponseWriter, request *http.Request) {
 	writer.WriteHeader(http.StatusNotFound)
 }
 
-func NewReMux() *ReMux {
-	return &ReMux{
-		notFoundHandler: http.HandlerFunc(defaultNotFoundHandler),
+var defaultMethodNotAllowedHandler = func(writer http.ResponseWriter, request *http.Request) {
+	writer.WriteHeader(http.StatusMethodNotAllowed)
+}
+
+var defaultOptionsHandler = func(writer http.ResponseWriter, request *http.Request) {
+	writer.WriteHeader(http.StatusNoContent)
+}
+
+func NewReMux(options ...Option) *ReMux {
+	mux := &ReMux{}
+	mux.table.Store(newTable())
+	for _, option := range options {
+		option(mux)
 	}
+	return mux
 }
 
 var (
@@ -47,6 +75,10 @@ var (
 	ErrNilHandler       = errors.New("nil handler")
 	ErrAmbiguousMapping = errors.New("ambiguous mapping")
 	ErrNoParams         = errors.New("no params")
+	ErrInvalidParamType = errors.New("invalid param type")
+	ErrInvalidName      = errors.New("invalid route name")
+	ErrRouteNotFound    = errors.New("route not found")
+	ErrInvalidParam     = errors.New("invalid param")
 )
 
 type Method string
@@ -61,6 +93,9 @@ const (
 	HEAD    Method = "HEAD"
 )
 
+// methods - поддерживаемые методы в порядке перечисления в заголовке Allow
+var methods = []Method{GET, HEAD, POST, PUT, PATCH, DELETE, OPTIONS}
+
 type Middleware func(handler http.Handler) http.Handler
 
 func (r *ReMux) RegisterPlain(
@@ -69,6 +104,10 @@ func (r *ReMux) RegisterPlain(
 	handler http.Handler,
 	middlewares ...Middleware,
 ) error {
+	return r.registerPlain(method, path, handler, r.chain(middlewares...), nil)
+}
+
+func (r *ReMux) registerPlain(method Method, path string, handler http.Handler, chain Chain, matchers []Matcher) error {
 	if !isValidMethod(method) {
 		return ErrInvalidMethod
 	}
@@ -81,26 +120,24 @@ func (r *ReMux) RegisterPlain(
 		return ErrNilHandler
 	}
 
-	handler = wrapHandler(handler, middlewares...)
-
-	r.mu.Lock()
-	defer r.mu.Unlock()
-
-	// запрещаем добавлять дубликаты
-	if _, exists := r.plain[method][path]; exists {
-		return ErrAmbiguousMapping
-	}
+	return r.update(func(t *table) error {
+		// запрещаем добавлять дубликаты (одинаковый путь допустим только с разными условиями)
+		if found, _ := t.tree[method].lookup(path); found != nil && found.pattern == path {
+			return ErrAmbiguousMapping
+		}
 
-	if r.plain == nil {
-		r.plain = make(map[Method]map[string]http.Handler)
-	}
+		updated, err := t.plain[method][path].add(&endpoint{handler: chain.Then(handler), chain: chain, matchers: matchers})
+		if err != nil {
+			return err
+		}
 
-	if r.plain[method] == nil {
-		r.plain[method] = make(map[string]http.Handler)
-	}
+		if t.plain[method] == nil {
+			t.plain[method] = make(map[string]endpoints)
+		}
 
-	r.plain[method][path] = handler
-	return nil
+		t.plain[method][path] = updated
+		return nil
+	})
 }
 
 func (r *ReMux) RegisterRegex(
@@ -109,6 +146,10 @@ func (r *ReMux) RegisterRegex(
 	handler http.Handler,
 	middlewares ...Middleware,
 ) error {
+	return r.registerRegex(method, path, handler, r.chain(middlewares...), nil)
+}
+
+func (r *ReMux) registerRegex(method Method, path *regexp.Regexp, handler http.Handler, chain Chain, matchers []Matcher) error {
 	if !isValidMethod(method) {
 		return ErrInvalidMethod
 	}
@@ -125,75 +166,139 @@ func (r *ReMux) RegisterRegex(
 		return ErrNilHandler
 	}
 
-	handler = wrapHandler(handler, middlewares...)
+	return r.update(func(t *table) error {
+		endpoint := &endpoint{handler: chain.Then(handler), chain: chain, matchers: matchers}
+		for _, existing := range t.regex[method] {
+			if existing.regex.String() == path.String() {
+				updated, err := existing.endpoints.add(endpoint)
+				if err != nil {
+					return err
+				}
+				existing.endpoints = updated
+				return nil
+			}
+		}
+
+		t.regex[method] = append(t.regex[method], &regexRoute{regex: path, endpoints: endpoints{endpoint}})
+		return nil
+	})
+}
+
+// RegisterPattern регистрирует обработчик по шаблону вида /users/{id:int}/orders/{orderID}/{rest...}.
+// Параметры попадают в Params (см. PathParams). При поиске приоритет у статических сегментов,
+// затем у типизированных параметров, затем у нетипизированных, затем у wildcard, и только потом у regex-маршрутов.
+func (r *ReMux) RegisterPattern(
+	method Method,
+	pattern string,
+	handler http.Handler,
+	middlewares ...Middleware,
+) error {
+	return r.registerPattern("", method, pattern, handler, r.chain(middlewares...), nil)
+}
 
-	r.mu.Lock()
-	defer r.mu.Unlock()
+// RegisterNamed регистрирует обработчик по шаблону (как RegisterPattern) под именем name,
+// по которому затем можно построить путь с помощью URL
+func (r *ReMux) RegisterNamed(
+	name string,
+	method Method,
+	pattern string,
+	handler http.Handler,
+	middlewares ...Middleware,
+) error {
+	if name == "" {
+		return ErrInvalidName
+	}
+	return r.registerPattern(name, method, pattern, handler, r.chain(middlewares...), nil)
+}
 
-	if _, exists := r.regex[method][path]; exists {
-		return ErrAmbiguousMapping
+func (r *ReMux) registerPattern(
+	name string,
+	method Method,
+	pattern string,
+	handler http.Handler,
+	chain Chain,
+	matchers []Matcher,
+) error {
+	if !isValidMethod(method) {
+		return ErrInvalidMethod
 	}
 
-	if r.regex == nil {
-		r.regex = make(map[Method]map[*regexp.Regexp]http.Handler)
+	segments, err := parsePattern(pattern)
+	if err != nil {
+		return err
 	}
 
-	if r.regex[method] == nil {
-		r.regex[method] = make(map[*regexp.Regexp]http.Handler)
+	if handler == nil {
+		return ErrNilHandler
 	}
 
-	r.regex[method][path] = handler
-	return nil
+	return r.update(func(t *table) error {
+		return t.insertPattern(name, method, pattern, segments, &endpoint{handler: chain.Then(handler), chain: chain, matchers: matchers})
+	})
 }
 
 func (r *ReMux) NotFound(handler http.Handler) error {
 	if handler == nil {
 		return ErrNilHandler
 	}
-	r.mu.Lock()
-	defer r.mu.Unlock()
-	r.notFoundHandler = handler
-	return nil
+	return r.update(func(t *table) error {
+		t.notFoundHandler = handler
+		return nil
+	})
+}
+
+// MethodNotAllowed устанавливает обработчик для случая, когда путь зарегистрирован, но для другого метода.
+// Заголовок Allow выставляется до вызова обработчика.
+func (r *ReMux) MethodNotAllowed(handler http.Handler) error {
+	if handler == nil {
+		return ErrNilHandler
+	}
+	return r.update(func(t *table) error {
+		t.methodNotAllowedHandler = handler
+		return nil
+	})
 }
 
 func (r *ReMux) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
-	r.mu.RLock()
-	var resultHandler http.Handler
-	if handlers, exists := r.plain[Method(request.Method)]; exists {
-		if handler, ok := handlers[request.URL.Path]; ok {
-			resultHandler = handler
+	method := Method(request.Method)
+
+	t := r.load()
+	if r.pathPolicy != PathStrict {
+		if canonical, ok := t.canonical(method, request); ok {
+			if r.pathPolicy == PathRedirect {
+				t.middlewares.Then(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+					redirect(writer, request, canonical)
+				})).ServeHTTP(writer, request)
+				return
+			}
+			request = withPath(request, canonical)
+		}
+	}
+
+	resultHandler, resultRequest := t.match(method, request)
+	if resultHandler == nil && method == HEAD {
+		// HEAD обслуживаем GET-обработчиком, отбрасывая тело ответа
+		if handler, req := t.match(GET, request); handler != nil {
+			resultHandler, resultRequest = handler, req
+			writer = &headResponseWriter{ResponseWriter: writer}
 		}
 	}
 	if resultHandler == nil {
-		if handlers, exists := r.regex[Method(request.Method)]; exists {
-			for path, handler := range handlers {
-				if matches := path.FindStringSubmatch(request.URL.Path); matches != nil {
-					params := &Params{
-						Positional: matches[1:],
-						Named:      make(map[string]string),
-					}
-					for index, name := range path.SubexpNames() {
-						if name == "" {
-							continue
-						}
-						params.Named[name] = matches[index]
-					}
-
-					ctx := context.WithValue(request.Context(), paramsContextKey, params)
-
-					request = request.WithContext(ctx)
-					resultHandler = handler
-					break
-				}
+		if allowed := t.allowed(request); len(allowed) != 0 {
+			writer.Header().Set("Allow", strings.Join(allowed, ", "))
+			if method == OPTIONS {
+				resultHandler = http.HandlerFunc(defaultOptionsHandler)
+			} else {
+				resultHandler = t.methodNotAllowedHandler
 			}
 		}
 	}
 	if resultHandler == nil {
-		resultHandler = r.notFoundHandler
+		resultHandler = t.notFoundHandler
 	}
-	r.mu.RUnlock()
+	resultHandler = t.middlewares.Then(resultHandler)
 
-	resultHandler.ServeHTTP(writer, request)
+	resultHandler.ServeHTTP(writer, resultRequest)
 }
 
 func PathParams(ctx context.Context) (*Params, error) {
@@ -204,18 +309,38 @@ func PathParams(ctx context.Context) (*Params, error) {
 	return params, nil
 }
 
-func wrapHandler(handler http.Handler, middlewares... Middleware) http.Handler {
-	for _, m := range middlewares {
-		handler = m(handler)
+// RoutePattern возвращает шаблон (путь или выражение), по которому был выбран обработчик запроса
+func RoutePattern(ctx context.Context) (string, error) {
+	pattern, ok := ctx.Value(routeContextKey).(string)
+	if !ok {
+		return "", ErrRouteNotFound
 	}
-	return handler
+	return pattern, nil
 }
 
 func isValidMethod(method Method) bool {
-	for _, m := range []Method{GET, POST, PUT, PATCH, DELETE} {
+	for _, m := range methods {
 		if m == method {
 			return true
 		}
 	}
 	return false
 }
+
+func containsMethod(methods []string, method Method) bool {
+	for _, m := range methods {
+		if m == string(method) {
+			return true
+		}
+	}
+	return false
+}
+
+// headResponseWriter отбрасывает тело ответа при обслуживании HEAD GET-обработчиком
+type headResponseWriter struct {
+	http.ResponseWriter
+}
+
+func (w *headResponseWriter) Write(data []byte) (int, error) {
+	return len(data), nil
+}
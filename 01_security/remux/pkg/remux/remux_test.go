@@ -5,6 +5,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"regexp"
+	"strings"
 	"github.com/netology-code/remux/pkg/middleware/logger"
 	"testing"
 )
@@ -134,3 +135,222 @@ func TestReMux_NotFound(t *testing.T) {
 		}
 	}
 }
+
+func TestReMux_Pattern(t *testing.T) {
+	mux := NewReMux()
+	register := func(method Method, pattern string, name string) {
+		if err := mux.RegisterPattern(method, pattern, http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+			params, err := PathParams(request.Context())
+			if err != nil {
+				t.Error(err)
+			}
+			writer.Write([]byte(name + " " + strings.Join(params.Positional, ",")))
+		})); err != nil {
+			t.Fatal(err)
+		}
+	}
+	register(GET, "/users/me", "static")
+	register(GET, "/users/{id:int}", "int")
+	register(GET, "/users/{login}", "param")
+	register(GET, "/users/{id:int}/orders/{orderID}", "orders")
+	register(GET, "/files/{path...}", "wildcard")
+	register(POST, "/users/{id:uuid}", "uuid")
+
+	regex, err := regexp.Compile(`^/users/(?P<login>[a-z]+)/orders$`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := mux.RegisterRegex(GET, regex, http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		writer.Write([]byte("regex"))
+	})); err != nil {
+		t.Fatal(err)
+	}
+
+	type args struct {
+		method Method
+		path   string
+	}
+
+	tests := []struct {
+		name string
+		args args
+		want []byte
+	}{
+		{name: "static", args: args{method: GET, path: "/users/me"}, want: []byte("static ")},
+		{name: "typed", args: args{method: GET, path: "/users/1"}, want: []byte("int 1")},
+		{name: "param", args: args{method: GET, path: "/users/admin"}, want: []byte("param admin")},
+		{name: "nested", args: args{method: GET, path: "/users/1/orders/2"}, want: []byte("orders 1,2")},
+		{name: "wildcard", args: args{method: GET, path: "/files/a/b.txt"}, want: []byte("wildcard a/b.txt")},
+		{name: "uuid", args: args{method: POST, path: "/users/5f0c8d3e-9a6b-4c1d-8e2f-3a4b5c6d7e8f"}, want: []byte("uuid 5f0c8d3e-9a6b-4c1d-8e2f-3a4b5c6d7e8f")},
+		{name: "regex", args: args{method: GET, path: "/users/admin/orders"}, want: []byte("regex")},
+		{name: "not found", args: args{method: POST, path: "/users/1"}, want: []byte{}},
+	}
+
+	for _, tt := range tests {
+		request := httptest.NewRequest(string(tt.args.method), tt.args.path, nil)
+		response := httptest.NewRecorder()
+		mux.ServeHTTP(response, request)
+		got := response.Body.Bytes()
+		if !bytes.Equal(tt.want, got) {
+			t.Errorf("%s: got %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestReMux_PatternErrors(t *testing.T) {
+	mux := NewReMux()
+	handler := http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {})
+	if err := mux.RegisterPattern(GET, "/users/{id:int}", handler); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		pattern string
+		want    error
+	}{
+		{name: "duplicate", pattern: "/users/{userID:int}", want: ErrAmbiguousMapping},
+		{name: "relative", pattern: "users", want: ErrInvalidPath},
+		{name: "unknown type", pattern: "/users/{id:float}", want: ErrInvalidParamType},
+		{name: "wildcard not last", pattern: "/files/{path...}/raw", want: ErrInvalidPath},
+		{name: "duplicate name", pattern: "/users/{id}/orders/{id}", want: ErrInvalidPath},
+	}
+
+	for _, tt := range tests {
+		if got := mux.RegisterPattern(GET, tt.pattern, handler); got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestReMux_MethodNotAllowed(t *testing.T) {
+	mux := NewReMux()
+	handler := http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		writer.Write([]byte(request.Method))
+	})
+	if err := mux.RegisterPlain(GET, "/items", handler); err != nil {
+		t.Fatal(err)
+	}
+	if err := mux.RegisterPattern(DELETE, "/items/{id:int}", handler); err != nil {
+		t.Fatal(err)
+	}
+
+	type args struct {
+		method Method
+		path   string
+	}
+
+	tests := []struct {
+		name      string
+		args      args
+		wantCode  int
+		wantAllow string
+		wantBody  []byte
+	}{
+		{name: "POST", args: args{method: POST, path: "/items"}, wantCode: http.StatusMethodNotAllowed, wantAllow: "GET, HEAD, OPTIONS", wantBody: []byte{}},
+		{name: "OPTIONS", args: args{method: OPTIONS, path: "/items"}, wantCode: http.StatusNoContent, wantAllow: "GET, HEAD, OPTIONS", wantBody: []byte{}},
+		{name: "HEAD", args: args{method: HEAD, path: "/items"}, wantCode: http.StatusOK, wantAllow: "", wantBody: []byte{}},
+		{name: "GET param", args: args{method: GET, path: "/items/1"}, wantCode: http.StatusMethodNotAllowed, wantAllow: "DELETE, OPTIONS", wantBody: []byte{}},
+		{name: "DELETE", args: args{method: DELETE, path: "/items/1"}, wantCode: http.StatusOK, wantAllow: "", wantBody: []byte(DELETE)},
+		{name: "not found", args: args{method: POST, path: "/items/abc"}, wantCode: http.StatusNotFound, wantAllow: "", wantBody: []byte{}},
+	}
+
+	for _, tt := range tests {
+		request := httptest.NewRequest(string(tt.args.method), tt.args.path, nil)
+		response := httptest.NewRecorder()
+		mux.ServeHTTP(response, request)
+		if got := response.Code; got != tt.wantCode {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.wantCode)
+		}
+		if got := response.Header().Get("Allow"); got != tt.wantAllow {
+			t.Errorf("%s: got %s, want %s", tt.name, got, tt.wantAllow)
+		}
+		if got := response.Body.Bytes(); !bytes.Equal(tt.wantBody, got) {
+			t.Errorf("%s: got %s, want %s", tt.name, got, tt.wantBody)
+		}
+	}
+}
+
+func TestReMux_Group(t *testing.T) {
+	mux := NewReMux()
+	handler := http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		writer.Write([]byte(request.URL.Path))
+	})
+	mux.Use(traceMd("use"))
+
+	api, err := mux.Group("/api", traceMd("api"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := api.RegisterPlain(GET, "/items", handler, traceMd("route")); err != nil {
+		t.Fatal(err)
+	}
+	admin, err := api.Group("/admin", traceMd("admin"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := admin.RegisterPattern(GET, "/users/{id:int}", handler); err != nil {
+		t.Fatal(err)
+	}
+
+	sub := NewReMux()
+	if err := sub.RegisterPlain(GET, "/status", handler); err != nil {
+		t.Fatal(err)
+	}
+	if err := mux.Mount("/sub", sub, traceMd("mount")); err != nil {
+		t.Fatal(err)
+	}
+
+	type args struct {
+		method Method
+		path   string
+	}
+
+	tests := []struct {
+		name string
+		args args
+		want []byte
+	}{
+		{name: "group", args: args{method: GET, path: "/api/items"}, want: []byte("use>api>route>/api/items")},
+		{name: "nested group", args: args{method: GET, path: "/api/admin/users/1"}, want: []byte("use>api>admin>/api/admin/users/1")},
+		{name: "mount", args: args{method: GET, path: "/sub/status"}, want: []byte("use>mount>/status")},
+		{name: "not found", args: args{method: GET, path: "/items"}, want: []byte("use>")},
+	}
+
+	for _, tt := range tests {
+		request := httptest.NewRequest(string(tt.args.method), tt.args.path, nil)
+		response := httptest.NewRecorder()
+		mux.ServeHTTP(response, request)
+		got := response.Body.Bytes()
+		if !bytes.Equal(tt.want, got) {
+			t.Errorf("%s: got %s, want %s", tt.name, got, tt.want)
+		}
+	}
+
+	// группа как обработчик в другом ReMux
+	other := NewReMux()
+	if err := other.Mount("/v1", api, traceMd("v1")); err != nil {
+		t.Fatal(err)
+	}
+	response := httptest.NewRecorder()
+	other.ServeHTTP(response, httptest.NewRequest(string(GET), "/v1/items", nil))
+	if got, want := response.Body.Bytes(), []byte("v1>use>api>route>/api/items"); !bytes.Equal(want, got) {
+		t.Errorf("mounted group: got %s, want %s", got, want)
+	}
+}
+
+func TestReMux_MountConflict(t *testing.T) {
+	mux := NewReMux()
+	handler := http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {})
+	if err := mux.RegisterPattern(POST, "/sub/{path...}", handler); err != nil {
+		t.Fatal(err)
+	}
+	before := len(mux.Routes())
+
+	if err := mux.Mount("/sub", NewReMux()); err != ErrAmbiguousMapping {
+		t.Errorf("got %v, want %v", err, ErrAmbiguousMapping)
+	}
+	if got := len(mux.Routes()); got != before {
+		t.Errorf("got %d routes after failed mount, want %d", got, before)
+	}
+}
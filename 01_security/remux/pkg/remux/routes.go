@@ -0,0 +1,155 @@
+package remux
+
+import (
+	"net/url"
+	"reflect"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// Route - описание зарегистрированного маршрута (для отладки и документации)
+type Route struct {
+	Method      Method
+	Pattern     string
+	Name        string
+	Middlewares []string // в порядке выполнения, включая middleware из Use
+	Matchers    []string // условия выбора (Host, Header, Query)
+}
+
+// namedRoute - маршрут, зарегистрированный через RegisterNamed
+type namedRoute struct {
+	method   Method
+	pattern  string
+	segments []segment
+}
+
+// Routes возвращает все зарегистрированные маршруты, отсортированные по шаблону и методу
+func (r *ReMux) Routes() []Route {
+	t := r.load()
+	names := make(map[string]string, len(t.names))
+	for name, named := range t.names {
+		names[string(named.method)+" "+named.pattern] = name
+	}
+
+	routes := make([]Route, 0)
+	// t.middlewares уже в порядке выполнения (см. Use) и выполняются до middleware маршрута
+	add := func(method Method, pattern string, endpoints endpoints) {
+		for _, endpoint := range endpoints {
+			routes = append(routes, Route{
+				Method:      method,
+				Pattern:     pattern,
+				Name:        names[string(method)+" "+pattern],
+				Middlewares: t.middlewares.Extend(endpoint.chain).names(),
+				Matchers:    matchersNames(endpoint.matchers),
+			})
+		}
+	}
+
+	for method, handlers := range t.plain {
+		for path, endpoints := range handlers {
+			add(method, path, endpoints)
+		}
+	}
+	for method, root := range t.tree {
+		root.walk(func(route *route) {
+			add(method, route.pattern, route.endpoints)
+		})
+	}
+	for method, handlers := range t.regex {
+		for _, route := range handlers {
+			add(method, route.regex.String(), route.endpoints)
+		}
+	}
+
+	sort.SliceStable(routes, func(i, j int) bool {
+		if routes[i].Pattern != routes[j].Pattern {
+			return routes[i].Pattern < routes[j].Pattern
+		}
+		return methodOrder(routes[i].Method) < methodOrder(routes[j].Method)
+	})
+	return routes
+}
+
+// URL строит путь для маршрута, зарегистрированного через RegisterNamed.
+// Все параметры шаблона должны быть переданы и соответствовать своему типу, лишние параметры запрещены.
+func (r *ReMux) URL(name string, params map[string]string) (string, error) {
+	named, ok := r.load().names[name]
+	if !ok {
+		return "", ErrRouteNotFound
+	}
+
+	used := 0
+	builder := strings.Builder{}
+	for _, seg := range named.segments {
+		builder.WriteString("/")
+		if seg.kind == kindStatic {
+			builder.WriteString(seg.value)
+			continue
+		}
+
+		value, ok := params[seg.name]
+		if !ok || value == "" {
+			return "", ErrInvalidParam
+		}
+		used++
+
+		if seg.kind == kindWildcard {
+			parts := strings.Split(value, "/")
+			for index, part := range parts {
+				parts[index] = url.PathEscape(part)
+			}
+			builder.WriteString(strings.Join(parts, "/"))
+			continue
+		}
+
+		if seg.typ != nil && !seg.typ.match(value) {
+			return "", ErrInvalidParam
+		}
+		builder.WriteString(url.PathEscape(value))
+	}
+
+	if used != len(params) {
+		return "", ErrInvalidParam
+	}
+
+	return builder.String(), nil
+}
+
+// walk обходит все маршруты поддерева
+func (n *node) walk(visit func(route *route)) {
+	if n.route != nil {
+		visit(n.route)
+	}
+	for _, child := range n.static {
+		child.walk(visit)
+	}
+	for _, child := range n.params {
+		child.walk(visit)
+	}
+	if n.wildcard != nil {
+		n.wildcard.walk(visit)
+	}
+}
+
+// names возвращает имена функций middleware цепочки, например authorizator.Authorizator.func1
+func (c Chain) names() []string {
+	names := make([]string, 0, len(c.middlewares))
+	for _, middleware := range c.middlewares {
+		name := runtime.FuncForPC(reflect.ValueOf(middleware).Pointer()).Name()
+		if slash := strings.LastIndex(name, "/"); slash != -1 {
+			name = name[slash+1:]
+		}
+		names = append(names, name)
+	}
+	return names
+}
+
+func methodOrder(method Method) int {
+	for index, m := range methods {
+		if m == method {
+			return index
+		}
+	}
+	return len(methods)
+}
@@ -0,0 +1,111 @@
+package remux
+
+import (
+	"net/http"
+	"reflect"
+	"regexp"
+	"testing"
+)
+
+func TestReMux_Routes(t *testing.T) {
+	mux := NewReMux(WithOrder(OrderDeclaration))
+	handler := http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {})
+	if err := mux.RegisterPlain(POST, "/login", handler, traceMd("login")); err != nil {
+		t.Fatal(err)
+	}
+	if err := mux.RegisterNamed("user", GET, "/users/{id:int}", handler); err != nil {
+		t.Fatal(err)
+	}
+	regex, err := regexp.Compile(`^/files/(?P<name>\w+)$`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := mux.RegisterRegex(GET, regex, handler); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []Route{
+		{Method: POST, Pattern: "/login", Middlewares: []string{"remux.traceMd.func1"}, Matchers: []string{}},
+		{Method: GET, Pattern: "/users/{id:int}", Name: "user", Middlewares: []string{}, Matchers: []string{}},
+		{Method: GET, Pattern: `^/files/(?P<name>\w+)$`, Middlewares: []string{}, Matchers: []string{}},
+	}
+	if got := mux.Routes(); !reflect.DeepEqual(want, got) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestReMux_URL(t *testing.T) {
+	mux := NewReMux()
+	handler := http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {})
+	if err := mux.RegisterNamed("order", GET, "/users/{id:int}/orders/{orderID}", handler); err != nil {
+		t.Fatal(err)
+	}
+	if err := mux.RegisterNamed("file", GET, "/files/{path...}", handler); err != nil {
+		t.Fatal(err)
+	}
+	if err := mux.RegisterNamed("file", GET, "/other", handler); err != ErrAmbiguousMapping {
+		t.Errorf("got %v, want %v", err, ErrAmbiguousMapping)
+	}
+
+	type args struct {
+		name   string
+		params map[string]string
+	}
+
+	tests := []struct {
+		name    string
+		args    args
+		want    string
+		wantErr error
+	}{
+		{name: "params", args: args{name: "order", params: map[string]string{"id": "1", "orderID": "a b"}}, want: "/users/1/orders/a%20b"},
+		{name: "wildcard", args: args{name: "file", params: map[string]string{"path": "docs/readme.md"}}, want: "/files/docs/readme.md"},
+		{name: "invalid type", args: args{name: "order", params: map[string]string{"id": "me", "orderID": "1"}}, wantErr: ErrInvalidParam},
+		{name: "missing", args: args{name: "order", params: map[string]string{"id": "1"}}, wantErr: ErrInvalidParam},
+		{name: "unknown", args: args{name: "file", params: map[string]string{"path": "a", "id": "1"}}, wantErr: ErrInvalidParam},
+		{name: "no route", args: args{name: "none"}, wantErr: ErrRouteNotFound},
+	}
+
+	for _, tt := range tests {
+		got, err := mux.URL(tt.args.name, tt.args.params)
+		if err != tt.wantErr {
+			t.Errorf("%s: got %v, want %v", tt.name, err, tt.wantErr)
+		}
+		if got != tt.want {
+			t.Errorf("%s: got %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestReMux_RoutesUse(t *testing.T) {
+	handler := http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {})
+
+	tests := []struct {
+		name  string
+		order Order
+		want  []string
+	}{
+		{name: "declaration", order: OrderDeclaration, want: []string{"remux.firstMd", "remux.secondMd", "remux.traceMd.func1"}},
+		{name: "reverse", order: OrderReverse, want: []string{"remux.secondMd", "remux.firstMd", "remux.traceMd.func1"}},
+	}
+
+	for _, tt := range tests {
+		mux := NewReMux(WithOrder(tt.order))
+		mux.Use(firstMd, secondMd)
+		if err := mux.RegisterPlain(GET, "/items", handler, traceMd("route")); err != nil {
+			t.Fatal(err)
+		}
+		routes := mux.Routes()
+		if len(routes) != 1 || !reflect.DeepEqual(routes[0].Middlewares, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, routes, tt.want)
+		}
+	}
+}
+
+func firstMd(handler http.Handler) http.Handler {
+	return handler
+}
+
+func secondMd(handler http.Handler) http.Handler {
+	return handler
+}
@@ -0,0 +1,300 @@
+package remux
+
+import (
+	"context"
+	"net/http"
+	"regexp"
+)
+
+// table - снимок таблицы маршрутов. Опубликованный снимок не изменяется:
+// изменения выполняются над копией, которая затем атомарно подменяет текущий снимок (copy-on-write),
+// поэтому запросы, уже начавшие обработку, дорабатывают со старой таблицей.
+type table struct {
+	plain                   map[Method]map[string]endpoints
+	tree                    map[Method]*node
+	regex                   map[Method][]*regexRoute
+	names                   map[string]*namedRoute
+	notFoundHandler         http.Handler
+	methodNotAllowedHandler http.Handler
+	middlewares             Chain
+}
+
+func newTable() *table {
+	return &table{
+		plain:                   make(map[Method]map[string]endpoints),
+		tree:                    make(map[Method]*node),
+		regex:                   make(map[Method][]*regexRoute),
+		names:                   make(map[string]*namedRoute),
+		notFoundHandler:         http.HandlerFunc(defaultNotFoundHandler),
+		methodNotAllowedHandler: http.HandlerFunc(defaultMethodNotAllowedHandler),
+	}
+}
+
+// clone возвращает копию таблицы, которую можно изменять, не затрагивая исходную
+func (t *table) clone() *table {
+	result := &table{
+		plain:                   make(map[Method]map[string]endpoints, len(t.plain)),
+		tree:                    make(map[Method]*node, len(t.tree)),
+		regex:                   make(map[Method][]*regexRoute, len(t.regex)),
+		names:                   make(map[string]*namedRoute, len(t.names)),
+		notFoundHandler:         t.notFoundHandler,
+		methodNotAllowedHandler: t.methodNotAllowedHandler,
+		middlewares:             t.middlewares,
+	}
+	for method, handlers := range t.plain {
+		result.plain[method] = make(map[string]endpoints, len(handlers))
+		for path, endpoints := range handlers {
+			result.plain[method][path] = endpoints
+		}
+	}
+	for method, root := range t.tree {
+		result.tree[method] = root.clone()
+	}
+	for method, handlers := range t.regex {
+		result.regex[method] = make([]*regexRoute, 0, len(handlers))
+		for _, route := range handlers {
+			copied := *route
+			result.regex[method] = append(result.regex[method], &copied)
+		}
+	}
+	for name, named := range t.names {
+		result.names[name] = named
+	}
+	return result
+}
+
+// insertPattern добавляет в дерево маршрут по разобранному шаблону
+func (t *table) insertPattern(name string, method Method, pattern string, segments []segment, endpoint *endpoint) error {
+	if _, exists := t.plain[method][pattern]; exists {
+		return ErrAmbiguousMapping
+	}
+
+	if _, exists := t.names[name]; exists && name != "" {
+		return ErrAmbiguousMapping
+	}
+
+	if t.tree[method] == nil {
+		t.tree[method] = &node{}
+	}
+
+	if err := t.tree[method].insert(pattern, segments, endpoint); err != nil {
+		return err
+	}
+
+	if name != "" {
+		t.names[name] = &namedRoute{method: method, pattern: pattern, segments: segments}
+	}
+	return nil
+}
+
+// load возвращает текущий снимок таблицы
+func (r *ReMux) load() *table {
+	t, ok := r.table.Load().(*table)
+	if !ok {
+		// ReMux создан без NewReMux
+		return newTable()
+	}
+	return t
+}
+
+// update применяет change к копии таблицы и публикует её, если change не вернул ошибку
+func (r *ReMux) update(change func(t *table) error) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	next := r.load().clone()
+	if err := change(next); err != nil {
+		return err
+	}
+	r.table.Store(next)
+	return nil
+}
+
+// Unregister удаляет маршрут, зарегистрированный для method по path
+// (путь для RegisterPlain, шаблон для RegisterPattern, выражение для RegisterRegex)
+func (r *ReMux) Unregister(method Method, path string) error {
+	return r.update(func(t *table) error {
+		if _, exists := t.plain[method][path]; exists {
+			delete(t.plain[method], path)
+			return nil
+		}
+
+		if segments, err := parsePattern(path); err == nil && t.tree[method].remove(path, segments) {
+			for name, named := range t.names {
+				if named.method == method && named.pattern == path {
+					delete(t.names, name)
+				}
+			}
+			return nil
+		}
+
+		for index, route := range t.regex[method] {
+			if route.regex.String() == path {
+				t.regex[method] = append(t.regex[method][:index], t.regex[method][index+1:]...)
+				return nil
+			}
+		}
+
+		return ErrRouteNotFound
+	})
+}
+
+// UnregisterRegex удаляет маршрут, зарегистрированный через RegisterRegex
+func (r *ReMux) UnregisterRegex(method Method, path *regexp.Regexp) error {
+	return r.Unregister(method, path.String())
+}
+
+// Swap атомарно заменяет все маршруты на маршруты source (обычно ReMux, подготовленный заранее).
+// Обработчики NotFound, MethodNotAllowed и middleware из Use остаются прежними.
+// Дальнейшие изменения source не влияют на r.
+func (r *ReMux) Swap(source *ReMux) {
+	routes := source.load()
+	_ = r.update(func(t *table) error {
+		t.plain = routes.plain
+		t.tree = routes.tree
+		t.regex = routes.regex
+		t.names = routes.names
+		return nil
+	})
+}
+
+// match ищет обработчик для метода и пути запроса.
+// Если маршрут содержит параметры, возвращается запрос с Params в контексте.
+// Если путь совпал, но условия (Matcher) не выполнены, поиск продолжается в следующей таблице: plain, дерево, regex.
+func (t *table) match(method Method, request *http.Request) (http.Handler, *http.Request) {
+	if handlers, exists := t.plain[method]; exists {
+		empty := &Params{Named: make(map[string]string), Positional: make([]string, 0)}
+		if found, params := handlers[request.URL.Path].match(request, empty); found != nil {
+			ctx := context.WithValue(request.Context(), routeContextKey, request.URL.Path)
+			if len(params.Named) != 0 {
+				ctx = context.WithValue(ctx, paramsContextKey, params)
+			}
+			return found.handler, request.WithContext(ctx)
+		}
+	}
+
+	if route, values := t.tree[method].lookupURL(request.URL); route != nil {
+		if found, params := route.endpoints.match(request, route.params(values)); found != nil {
+			ctx := context.WithValue(request.Context(), routeContextKey, route.pattern)
+			ctx = context.WithValue(ctx, paramsContextKey, params)
+			return found.handler, request.WithContext(ctx)
+		}
+	}
+
+	for _, route := range t.regex[method] {
+		if matches := route.regex.FindStringSubmatch(request.URL.Path); matches != nil {
+			params := &Params{
+				Positional: matches[1:],
+				Named:      make(map[string]string),
+			}
+			for index, name := range route.regex.SubexpNames() {
+				if name == "" {
+					continue
+				}
+				params.Named[name] = matches[index]
+			}
+
+			if found, params := route.endpoints.match(request, params); found != nil {
+				ctx := context.WithValue(request.Context(), routeContextKey, route.regex.String())
+				ctx = context.WithValue(ctx, paramsContextKey, params)
+				return found.handler, request.WithContext(ctx)
+			}
+		}
+	}
+
+	return nil, request
+}
+
+// allowed возвращает методы, для которых зарегистрирован путь запроса
+func (t *table) allowed(request *http.Request) []string {
+	allowed := make([]string, 0)
+	for _, method := range methods {
+		if handler, _ := t.match(method, request); handler != nil {
+			allowed = append(allowed, string(method))
+			continue
+		}
+		if method == HEAD {
+			if handler, _ := t.match(GET, request); handler != nil {
+				allowed = append(allowed, string(method))
+			}
+		}
+	}
+	if len(allowed) != 0 && !containsMethod(allowed, OPTIONS) {
+		allowed = append(allowed, string(OPTIONS))
+	}
+	return allowed
+}
+
+// clone возвращает глубокую копию поддерева (обработчики не копируются)
+func (n *node) clone() *node {
+	if n == nil {
+		return nil
+	}
+	result := &node{kind: n.kind, typ: n.typ, wildcard: n.wildcard.clone()}
+	if n.route != nil {
+		copied := *n.route
+		result.route = &copied
+	}
+	if n.static != nil {
+		result.static = make(map[string]*node, len(n.static))
+		for value, child := range n.static {
+			result.static[value] = child.clone()
+		}
+	}
+	for _, child := range n.params {
+		result.params = append(result.params, child.clone())
+	}
+	return result
+}
+
+// remove удаляет маршрут по разобранному шаблону, удаляя опустевшие узлы
+func (n *node) remove(pattern string, segments []segment) bool {
+	if n == nil {
+		return false
+	}
+	if len(segments) == 0 {
+		if n.route == nil || n.route.pattern != pattern {
+			return false
+		}
+		n.route = nil
+		return true
+	}
+
+	seg := segments[0]
+	switch seg.kind {
+	case kindStatic:
+		child := n.static[seg.value]
+		if !child.remove(pattern, segments[1:]) {
+			return false
+		}
+		if child.empty() {
+			delete(n.static, seg.value)
+		}
+	case kindWildcard:
+		if !n.wildcard.remove(pattern, segments[1:]) {
+			return false
+		}
+		if n.wildcard.empty() {
+			n.wildcard = nil
+		}
+	default:
+		for index, child := range n.params {
+			if child.kind != seg.kind || child.typ != seg.typ {
+				continue
+			}
+			if !child.remove(pattern, segments[1:]) {
+				return false
+			}
+			if child.empty() {
+				n.params = append(n.params[:index:index], n.params[index+1:]...)
+			}
+			return true
+		}
+		return false
+	}
+	return true
+}
+
+func (n *node) empty() bool {
+	return n.route == nil && len(n.static) == 0 && len(n.params) == 0 && n.wildcard == nil
+}
@@ -0,0 +1,114 @@
+package remux
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"sync"
+	"testing"
+)
+
+func TestReMux_Unregister(t *testing.T) {
+	mux := NewReMux()
+	handler := http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		writer.Write([]byte(request.URL.Path))
+	})
+	if err := mux.RegisterPlain(GET, "/plain", handler); err != nil {
+		t.Fatal(err)
+	}
+	if err := mux.RegisterNamed("user", GET, "/users/{id:int}", handler); err != nil {
+		t.Fatal(err)
+	}
+	if err := mux.RegisterPattern(GET, "/users/{id:int}/orders", handler); err != nil {
+		t.Fatal(err)
+	}
+	regex, err := regexp.Compile(`^/files/(?P<name>\w+)$`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := mux.RegisterRegex(GET, regex, handler); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := mux.Unregister(GET, "/plain"); err != nil {
+		t.Fatal(err)
+	}
+	if err := mux.Unregister(GET, "/users/{userID:int}"); err != ErrRouteNotFound {
+		t.Errorf("got %v, want %v", err, ErrRouteNotFound)
+	}
+	if err := mux.Unregister(GET, "/users/{id:int}"); err != nil {
+		t.Fatal(err)
+	}
+	if err := mux.UnregisterRegex(GET, regex); err != nil {
+		t.Fatal(err)
+	}
+	if err := mux.Unregister(POST, "/users/{id:int}/orders"); err != ErrRouteNotFound {
+		t.Errorf("got %v, want %v", err, ErrRouteNotFound)
+	}
+	if _, err := mux.URL("user", map[string]string{"id": "1"}); err != ErrRouteNotFound {
+		t.Errorf("got %v, want %v", err, ErrRouteNotFound)
+	}
+
+	tests := []struct {
+		name string
+		path string
+		want int
+	}{
+		{name: "plain", path: "/plain", want: http.StatusNotFound},
+		{name: "pattern", path: "/users/1", want: http.StatusNotFound},
+		{name: "regex", path: "/files/readme", want: http.StatusNotFound},
+		{name: "sibling", path: "/users/1/orders", want: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		response := httptest.NewRecorder()
+		mux.ServeHTTP(response, httptest.NewRequest(http.MethodGet, tt.path, nil))
+		if got := response.Code; got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestReMux_Swap(t *testing.T) {
+	mux := NewReMux()
+	version := func(name string) http.Handler {
+		return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+			writer.Write([]byte(name))
+		})
+	}
+	if err := mux.RegisterPlain(GET, "/version", version("v1")); err != nil {
+		t.Fatal(err)
+	}
+
+	next := NewReMux()
+	if err := next.RegisterPlain(GET, "/version", version("v2")); err != nil {
+		t.Fatal(err)
+	}
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			response := httptest.NewRecorder()
+			mux.ServeHTTP(response, httptest.NewRequest(http.MethodGet, "/version", nil))
+			if got := response.Body.String(); got != "v1" && got != "v2" {
+				t.Errorf("got %s, want v1 or v2", got)
+			}
+		}()
+	}
+	mux.Swap(next)
+	wg.Wait()
+
+	// изменения source после Swap не видны
+	if err := next.Unregister(GET, "/version"); err != nil {
+		t.Fatal(err)
+	}
+
+	response := httptest.NewRecorder()
+	mux.ServeHTTP(response, httptest.NewRequest(http.MethodGet, "/version", nil))
+	if got := response.Body.Bytes(); !bytes.Equal([]byte("v2"), got) {
+		t.Errorf("got %s, want %s", got, "v2")
+	}
+}
@@ -0,0 +1,289 @@
+package remux
+
+import (
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// kind определяет приоритет сегмента при поиске: чем меньше, тем раньше проверяется
+type kind int
+
+const (
+	kindStatic kind = iota
+	kindTyped
+	kindParam
+	kindWildcard
+)
+
+// paramType - тип параметра в шаблоне пути вида {name:type}
+type paramType struct {
+	name  string
+	order int // порядок проверки среди типизированных параметров одного узла
+	match func(value string) bool
+}
+
+var paramTypes = map[string]*paramType{
+	"int":  {name: "int", order: 0, match: isInt},
+	"uint": {name: "uint", order: 1, match: isUint},
+	"uuid": {name: "uuid", order: 2, match: isUUID},
+}
+
+// segment - разобранный сегмент шаблона
+type segment struct {
+	kind  kind
+	value string // литерал для kindStatic
+	name  string // имя параметра
+	typ   *paramType
+}
+
+// route - зарегистрированный обработчик в листе дерева
+type route struct {
+	pattern   string
+	names     []string // имена параметров в порядке следования в шаблоне
+	endpoints endpoints
+}
+
+// node - узел префиксного дерева (один узел на один сегмент пути)
+type node struct {
+	static   map[string]*node
+	params   []*node // отсортированы по приоритету: сначала типизированные, потом нетипизированный
+	wildcard *node
+	kind     kind
+	typ      *paramType
+	route    *route
+}
+
+// parsePattern разбирает шаблон вида /users/{id:int}/orders/{orderID}/{rest...}
+func parsePattern(pattern string) ([]segment, error) {
+	if !strings.HasPrefix(pattern, "/") {
+		return nil, ErrInvalidPath
+	}
+
+	parts := strings.Split(pattern[1:], "/")
+	segments := make([]segment, 0, len(parts))
+	names := make(map[string]struct{})
+	for index, part := range parts {
+		if !strings.HasPrefix(part, "{") {
+			if strings.ContainsAny(part, "{}") {
+				return nil, ErrInvalidPath
+			}
+			segments = append(segments, segment{kind: kindStatic, value: part})
+			continue
+		}
+
+		if !strings.HasSuffix(part, "}") {
+			return nil, ErrInvalidPath
+		}
+		body := part[1 : len(part)-1]
+
+		seg := segment{kind: kindParam, name: body}
+		if strings.HasSuffix(body, "...") {
+			// wildcard допустим только последним сегментом
+			if index != len(parts)-1 {
+				return nil, ErrInvalidPath
+			}
+			seg = segment{kind: kindWildcard, name: strings.TrimSuffix(body, "...")}
+		} else if colon := strings.IndexByte(body, ':'); colon != -1 {
+			typ, ok := paramTypes[body[colon+1:]]
+			if !ok {
+				return nil, ErrInvalidParamType
+			}
+			seg = segment{kind: kindTyped, name: body[:colon], typ: typ}
+		}
+
+		if seg.name == "" || strings.ContainsAny(seg.name, "{}:") {
+			return nil, ErrInvalidPath
+		}
+		if _, exists := names[seg.name]; exists {
+			return nil, ErrInvalidPath
+		}
+		names[seg.name] = struct{}{}
+		segments = append(segments, seg)
+	}
+
+	return segments, nil
+}
+
+// insert добавляет маршрут в дерево, возвращает ErrAmbiguousMapping, если такой шаблон с теми же условиями уже есть
+func (n *node) insert(pattern string, segments []segment, endpoint *endpoint) error {
+	current := n
+	names := make([]string, 0)
+	for _, seg := range segments {
+		current = current.child(seg)
+		if seg.kind != kindStatic {
+			names = append(names, seg.name)
+		}
+	}
+
+	if current.route == nil {
+		current.route = &route{pattern: pattern, names: names, endpoints: endpoints{endpoint}}
+		return nil
+	}
+
+	// один и тот же шаблон может быть зарегистрирован с разными условиями
+	if current.route.pattern != pattern {
+		return ErrAmbiguousMapping
+	}
+	updated, err := current.route.endpoints.add(endpoint)
+	if err != nil {
+		return err
+	}
+	current.route.endpoints = updated
+	return nil
+}
+
+// child возвращает (при необходимости создавая) дочерний узел для сегмента
+func (n *node) child(seg segment) *node {
+	switch seg.kind {
+	case kindStatic:
+		if n.static == nil {
+			n.static = make(map[string]*node)
+		}
+		child, ok := n.static[seg.value]
+		if !ok {
+			child = &node{kind: kindStatic}
+			n.static[seg.value] = child
+		}
+		return child
+	case kindWildcard:
+		if n.wildcard == nil {
+			n.wildcard = &node{kind: kindWildcard}
+		}
+		return n.wildcard
+	default:
+		for _, child := range n.params {
+			if child.kind == seg.kind && child.typ == seg.typ {
+				return child
+			}
+		}
+		child := &node{kind: seg.kind, typ: seg.typ}
+		n.params = append(n.params, child)
+		sort.SliceStable(n.params, func(i, j int) bool {
+			if n.params[i].kind != n.params[j].kind {
+				return n.params[i].kind < n.params[j].kind
+			}
+			return n.params[i].typ.order < n.params[j].typ.order
+		})
+		return child
+	}
+}
+
+// lookup ищет маршрут для пути, values - значения параметров в порядке следования
+func (n *node) lookup(path string) (*route, []string) {
+	if n == nil || !strings.HasPrefix(path, "/") {
+		return nil, nil
+	}
+	return n.match(path[1:], false, make([]string, 0))
+}
+
+// lookupURL ищет маршрут для URL запроса. Если задан RawPath (в пути есть, например, %2F),
+// путь делится на сегменты по экранированной форме, а значения сегментов декодируются,
+// поэтому /files/a%2Fb совпадает с /files/{name}, а не с /files/{dir}/{name}.
+func (n *node) lookupURL(u *url.URL) (*route, []string) {
+	if n == nil || u.RawPath == "" {
+		return n.lookup(u.Path)
+	}
+	path := u.EscapedPath()
+	if !strings.HasPrefix(path, "/") {
+		return nil, nil
+	}
+	return n.match(path[1:], true, make([]string, 0))
+}
+
+func (n *node) match(path string, escaped bool, values []string) (*route, []string) {
+	part, rest, last := path, "", true
+	if slash := strings.IndexByte(path, '/'); slash != -1 {
+		part, rest, last = path[:slash], path[slash+1:], false
+	}
+
+	if escaped {
+		decoded, err := url.PathUnescape(part)
+		if err != nil {
+			return nil, nil
+		}
+		part = decoded
+	}
+
+	if child, ok := n.static[part]; ok {
+		if found, params := child.next(rest, last, escaped, values); found != nil {
+			return found, params
+		}
+	}
+
+	if part != "" {
+		for _, child := range n.params {
+			if child.typ != nil && !child.typ.match(part) {
+				continue
+			}
+			if found, params := child.next(rest, last, escaped, append(values, part)); found != nil {
+				return found, params
+			}
+		}
+	}
+
+	if n.wildcard != nil && n.wildcard.route != nil && path != "" {
+		if escaped {
+			decoded, err := url.PathUnescape(path)
+			if err != nil {
+				return nil, nil
+			}
+			path = decoded
+		}
+		return n.wildcard.route, append(values, path)
+	}
+
+	return nil, nil
+}
+
+func (n *node) next(rest string, last bool, escaped bool, values []string) (*route, []string) {
+	if last {
+		if n.route == nil {
+			return nil, nil
+		}
+		return n.route, values
+	}
+	return n.match(rest, escaped, values)
+}
+
+// params формирует Params по найденному маршруту
+func (r *route) params(values []string) *Params {
+	params := &Params{
+		Named:      make(map[string]string, len(values)),
+		Positional: values,
+	}
+	for index, name := range r.names {
+		params.Named[name] = values[index]
+	}
+	return params
+}
+
+func isInt(value string) bool {
+	_, err := strconv.ParseInt(value, 10, 64)
+	return err == nil
+}
+
+func isUint(value string) bool {
+	_, err := strconv.ParseUint(value, 10, 64)
+	return err == nil
+}
+
+func isUUID(value string) bool {
+	if len(value) != 36 {
+		return false
+	}
+	for index, c := range value {
+		switch index {
+		case 8, 13, 18, 23:
+			if c != '-' {
+				return false
+			}
+		default:
+			if !strings.ContainsRune("0123456789abcdefABCDEF", c) {
+				return false
+			}
+		}
+	}
+	return true
+}
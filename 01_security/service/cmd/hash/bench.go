@@ -0,0 +1,99 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"golang.org/x/crypto/bcrypt"
+	"io"
+	"time"
+)
+
+var ErrTargetTooLow = errors.New("even the weakest parameters exceed target latency")
+
+// Пределы перебора параметров при замере
+const (
+	maxArgon2Time = 20
+	minScryptLogN = 10
+	maxScryptLogN = 22
+)
+
+type candidate struct {
+	params params
+	label  string
+}
+
+// benchmark подбирает самый сильный параметр алгоритма (cost для bcrypt, t для argon2id, ln для scrypt),
+// при котором хэширование на этой машине занимает не больше target. Замеры пишутся в out.
+func benchmark(algorithm string, p params, target time.Duration, out io.Writer) (params, error) {
+	candidates, err := benchmarkCandidates(algorithm, p)
+	if err != nil {
+		return p, err
+	}
+
+	password := []byte("benchmark password")
+	found := false
+	best := p
+	for _, c := range candidates {
+		elapsed, err := measure(algorithm, c.params, password)
+		if err != nil {
+			return p, err
+		}
+		fmt.Fprintf(out, "%s: %v\n", c.label, elapsed.Round(time.Millisecond))
+		// время растёт с параметром, дальше будет только медленнее
+		if elapsed > target {
+			break
+		}
+		best = c.params
+		found = true
+	}
+
+	if !found {
+		return p, fmt.Errorf("%w (%v)", ErrTargetTooLow, target)
+	}
+	return best, nil
+}
+
+// benchmarkCandidates перечисляет параметры по возрастанию стоимости, остальные параметры берутся из p
+func benchmarkCandidates(algorithm string, p params) ([]candidate, error) {
+	candidates := make([]candidate, 0)
+	switch algorithm {
+	case algoBcrypt:
+		for cost := bcrypt.MinCost; cost <= bcrypt.MaxCost; cost++ {
+			c := p
+			c.bcryptCost = cost
+			candidates = append(candidates, candidate{params: c, label: fmt.Sprintf("cost=%d", cost)})
+		}
+	case algoArgon2id:
+		for t := uint32(1); t <= maxArgon2Time; t++ {
+			c := p
+			c.argon2Time = t
+			candidates = append(candidates, candidate{params: c, label: fmt.Sprintf("m=%d,t=%d,p=%d", p.argon2Memory, t, p.argon2Threads)})
+		}
+	case algoScrypt:
+		for logN := minScryptLogN; logN <= maxScryptLogN; logN++ {
+			c := p
+			c.scryptLogN = logN
+			candidates = append(candidates, candidate{params: c, label: fmt.Sprintf("ln=%d,r=%d,p=%d", logN, p.scryptR, p.scryptP)})
+		}
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnknownAlgorithm, algorithm)
+	}
+	return candidates, nil
+}
+
+// measure возвращает лучшее время из нескольких хэширований (так меньше влияют прогрев и соседние процессы)
+func measure(algorithm string, p params, password []byte) (time.Duration, error) {
+	const runs = 3
+	var best time.Duration
+	for i := 0; i < runs; i++ {
+		started := time.Now()
+		if _, err := hash(algorithm, p, password); err != nil {
+			return 0, err
+		}
+		elapsed := time.Since(started)
+		if i == 0 || elapsed < best {
+			best = elapsed
+		}
+	}
+	return best, nil
+}
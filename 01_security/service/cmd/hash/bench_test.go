@@ -0,0 +1,34 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestBenchmarkCandidates(t *testing.T) {
+	p := params{argon2Memory: 1024, argon2Threads: 1, scryptR: 8, scryptP: 1}
+
+	tests := []struct {
+		algorithm string
+		first     string
+		last      string
+	}{
+		{algorithm: algoBcrypt, first: "cost=4", last: "cost=31"},
+		{algorithm: algoArgon2id, first: "m=1024,t=1,p=1", last: "m=1024,t=20,p=1"},
+		{algorithm: algoScrypt, first: "ln=10,r=8,p=1", last: "ln=22,r=8,p=1"},
+	}
+
+	for _, tt := range tests {
+		candidates, err := benchmarkCandidates(tt.algorithm, p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if first, last := candidates[0].label, candidates[len(candidates)-1].label; first != tt.first || last != tt.last {
+			t.Errorf("%s: got %v..%v, want %v..%v", tt.algorithm, first, last, tt.first, tt.last)
+		}
+	}
+
+	if _, err := benchmarkCandidates("md5", p); !errors.Is(err, ErrUnknownAlgorithm) {
+		t.Errorf("got %v, want %v", err, ErrUnknownAlgorithm)
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"service/pkg/security"
+)
+
+var ErrUnknownAlgorithm = errors.New("unknown algorithm")
+
+// Алгоритмы хэширования (см. security.Hashers)
+const (
+	algoBcrypt   = "bcrypt"
+	algoArgon2id = "argon2id"
+	algoScrypt   = "scrypt"
+)
+
+// params - параметры всех алгоритмов, используются только относящиеся к выбранному
+type params struct {
+	bcryptCost int
+
+	argon2Time    uint32
+	argon2Memory  uint32 // KiB
+	argon2Threads uint8
+
+	scryptLogN int
+	scryptR    int
+	scryptP    int
+}
+
+func hasher(algorithm string, p params) (security.PasswordHasher, error) {
+	switch algorithm {
+	case algoBcrypt:
+		return &security.BcryptHasher{Cost: p.bcryptCost}, nil
+	case algoArgon2id:
+		return &security.Argon2idHasher{Time: p.argon2Time, Memory: p.argon2Memory, Threads: p.argon2Threads}, nil
+	case algoScrypt:
+		return &security.ScryptHasher{LogN: p.scryptLogN, R: p.scryptR, P: p.scryptP}, nil
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnknownAlgorithm, algorithm)
+	}
+}
+
+func hash(algorithm string, p params, password []byte) (string, error) {
+	h, err := hasher(algorithm, p)
+	if err != nil {
+		return "", err
+	}
+	return h.Hash(password)
+}
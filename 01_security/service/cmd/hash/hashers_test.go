@@ -0,0 +1,25 @@
+package main
+
+import (
+	"errors"
+	"service/pkg/security"
+	"testing"
+)
+
+func TestHash(t *testing.T) {
+	p := params{bcryptCost: 4, argon2Time: 1, argon2Memory: 1024, argon2Threads: 1, scryptLogN: 10, scryptR: 8, scryptP: 1}
+
+	for _, algorithm := range []string{algoBcrypt, algoArgon2id, algoScrypt} {
+		hashed, err := hash(algorithm, p, []byte("secret"))
+		if err != nil {
+			t.Fatalf("%s: %v", algorithm, err)
+		}
+		if err := security.VerifyPassword(hashed, []byte("secret")); err != nil {
+			t.Errorf("%s: got %v, want nil", algorithm, err)
+		}
+	}
+
+	if _, err := hash("md5", p, []byte("secret")); !errors.Is(err, ErrUnknownAlgorithm) {
+		t.Errorf("got %v, want %v", err, ErrUnknownAlgorithm)
+	}
+}
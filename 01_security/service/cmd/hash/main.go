@@ -1,21 +1,157 @@
+// Команда hash хэширует пароли для таблицы users. Пароль читается из терминала без эха или из stdin.
+//
+// Пароли нельзя хранить в открытом виде, т.к. большинство пользователей использует одни и те же пароли на всех сервисах.
+// Поэтому их хэшируют - md5, sha1 уже считаются небезопасными, поэтому используем bcrypt (или argon2id, scrypt):
+//
+//	hash                                        хэш bcrypt с cost по умолчанию
+//	hash -algo argon2id -argon2-memory 131072   хэш argon2id
+//	hash -login admin -roles ADMIN,USER         готовый INSERT INTO users
+//	hash -verify '$2a$10$...'                   проверка пароля (код выхода 1 - не совпадает)
+//	hash -algo bcrypt -bench 250ms              подбор параметров под время хэширования на этой машине
 package main
 
 import (
+	"errors"
+	"flag"
+	"fmt"
 	"golang.org/x/crypto/bcrypt"
 	"log"
 	"os"
+	"service/pkg/security"
+	"strings"
+	"time"
+)
+
+// Коды выхода
+const (
+	exitMismatch = 1
+	exitError    = 2
 )
 
 func main() {
-	// Пароли нельзя хранить в открытом виде, т.к. большинство пользователей использует одни и те же пароли не всех сервисах.
-	// Поэтому их хэшируют - md5, sha1 уже считаются небезопасными, поэтому используем bcrypt
-	hash, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.DefaultCost)
+	log.SetFlags(0)
+
+	algorithm := flag.String("algo", algoBcrypt, "algorithm: bcrypt, argon2id or scrypt")
+	bcryptCost := flag.Int("cost", bcrypt.DefaultCost, "bcrypt cost")
+	argon2Time := flag.Uint("argon2-time", 3, "argon2id iterations")
+	argon2Memory := flag.Uint("argon2-memory", 64*1024, "argon2id memory in KiB")
+	argon2Threads := flag.Uint("argon2-threads", 2, "argon2id parallelism")
+	scryptLogN := flag.Int("scrypt-ln", 15, "scrypt log2(N)")
+	scryptR := flag.Int("scrypt-r", 8, "scrypt block size")
+	scryptP := flag.Int("scrypt-p", 1, "scrypt parallelism")
+	verifyHash := flag.String("verify", "", "verify password against this hash instead of hashing")
+	bench := flag.Duration("bench", 0, "find the strongest parameters hashing within this time, e.g. 250ms")
+	login := flag.String("login", "", "print INSERT INTO users statement for this login")
+	roles := flag.String("roles", security.RoleUser, "comma-separated roles for -login")
+	flag.Parse()
+
+	if flag.NArg() != 0 {
+		flag.Usage()
+		os.Exit(exitError)
+	}
+	if *argon2Threads == 0 || *argon2Threads > 255 {
+		log.Print("-argon2-threads must be in [1, 255]")
+		os.Exit(exitError)
+	}
+
+	p := params{
+		bcryptCost:    *bcryptCost,
+		argon2Time:    uint32(*argon2Time),
+		argon2Memory:  uint32(*argon2Memory),
+		argon2Threads: uint8(*argon2Threads),
+		scryptLogN:    *scryptLogN,
+		scryptR:       *scryptR,
+		scryptP:       *scryptP,
+	}
+
+	switch {
+	case *bench != 0:
+		os.Exit(runBenchmark(*algorithm, p, *bench))
+	case *verifyHash != "":
+		os.Exit(runVerify(*verifyHash))
+	default:
+		os.Exit(runHash(*algorithm, p, *login, *roles))
+	}
+}
+
+func runHash(algorithm string, p params, login string, roles string) int {
+	var roleList []string
+	if login != "" {
+		roleList = strings.Split(roles, ",")
+		for _, role := range roleList {
+			if !isKnownRole(role) {
+				log.Printf("unknown role %q, known roles: %s", role, strings.Join(security.Roles, ", "))
+				return exitError
+			}
+		}
+	}
+
+	password, err := readPassword(true)
+	if err != nil {
+		log.Print(err)
+		return exitError
+	}
+
+	// hash будет каждый раз разным для одних и тех же данных - это нормально (соль)
+	hashed, err := hash(algorithm, p, password)
+	if err != nil {
+		log.Print(err)
+		return exitError
+	}
+
+	if login == "" {
+		fmt.Println(hashed)
+		return 0
+	}
+	fmt.Println(insertStatement(login, hashed, roleList))
+	return 0
+}
+
+func runVerify(hashed string) int {
+	password, err := readPassword(false)
+	if err != nil {
+		log.Print(err)
+		return exitError
+	}
+
+	err = security.VerifyPassword(hashed, password)
+	if errors.Is(err, security.ErrInvalidPassword) {
+		log.Print(err)
+		return exitMismatch
+	}
+	if err != nil {
+		log.Print(err)
+		return exitError
+	}
+	log.Print("password matches hash")
+	return 0
+}
+
+// runBenchmark пишет замеры в stderr, а подобранные параметры - в stdout в виде флагов
+func runBenchmark(algorithm string, p params, target time.Duration) int {
+	best, err := benchmark(algorithm, p, target, os.Stderr)
 	if err != nil {
 		log.Print(err)
-		os.Exit(-1)
-		return
+		return exitError
 	}
 
-	// hash будет каждый раз разным для одних и тех же данных - это нормально
-	log.Print(string(hash))
+	switch algorithm {
+	case algoBcrypt:
+		fmt.Printf("-algo %s -cost %d\n", algorithm, best.bcryptCost)
+	case algoArgon2id:
+		fmt.Printf("-algo %s -argon2-time %d -argon2-memory %d -argon2-threads %d\n",
+			algorithm, best.argon2Time, best.argon2Memory, best.argon2Threads)
+	case algoScrypt:
+		fmt.Printf("-algo %s -scrypt-ln %d -scrypt-r %d -scrypt-p %d\n", algorithm, best.scryptLogN, best.scryptR, best.scryptP)
+	}
+	return 0
+}
+
+func isKnownRole(role string) bool {
+	for _, known := range security.Roles {
+		if role == known {
+			return true
+		}
+	}
+	return false
 }
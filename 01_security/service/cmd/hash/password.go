@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"golang.org/x/crypto/ssh/terminal"
+	"io"
+	"os"
+	"strings"
+)
+
+var (
+	ErrEmptyPassword    = errors.New("empty password")
+	ErrPasswordMismatch = errors.New("passwords do not match")
+)
+
+// readPassword читает пароль из терминала без эха (с подтверждением, если confirm)
+// или первой строкой stdin, если он перенаправлен: echo -n secret | hash
+func readPassword(confirm bool) ([]byte, error) {
+	fd := int(os.Stdin.Fd())
+	if !terminal.IsTerminal(fd) {
+		line, err := bufio.NewReader(os.Stdin).ReadBytes('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return nil, err
+		}
+		password := bytes.TrimRight(line, "\r\n")
+		if len(password) == 0 {
+			return nil, ErrEmptyPassword
+		}
+		return password, nil
+	}
+
+	password, err := prompt(fd, "Password: ")
+	if err != nil {
+		return nil, err
+	}
+	if len(password) == 0 {
+		return nil, ErrEmptyPassword
+	}
+	if confirm {
+		repeated, err := prompt(fd, "Repeat password: ")
+		if err != nil {
+			return nil, err
+		}
+		if !bytes.Equal(password, repeated) {
+			return nil, ErrPasswordMismatch
+		}
+	}
+	return password, nil
+}
+
+// prompt выводит приглашение в stderr, чтобы в stdout оставался только результат
+func prompt(fd int, text string) ([]byte, error) {
+	fmt.Fprint(os.Stderr, text)
+	password, err := terminal.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	return password, err
+}
+
+// insertStatement формирует INSERT для docker-entrypoint-initdb.d
+func insertStatement(login string, hash string, roles []string) string {
+	quoted := make([]string, 0, len(roles))
+	for _, role := range roles {
+		quoted = append(quoted, `"`+role+`"`)
+	}
+	return fmt.Sprintf("INSERT INTO users(login, password, roles)\nVALUES (%s, %s, %s);",
+		sqlString(login), sqlString(hash), sqlString("{"+strings.Join(quoted, ", ")+"}"))
+}
+
+func sqlString(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", "''") + "'"
+}
@@ -0,0 +1,39 @@
+package main
+
+import "testing"
+
+func TestInsertStatement(t *testing.T) {
+	type args struct {
+		login string
+		hash  string
+		roles []string
+	}
+
+	tests := []struct {
+		name string
+		args args
+		want string
+	}{
+		{
+			name: "roles",
+			args: args{login: "admin", hash: "$2a$10$hash", roles: []string{"ADMIN", "USER"}},
+			want: "INSERT INTO users(login, password, roles)\nVALUES ('admin', '$2a$10$hash', '{\"ADMIN\", \"USER\"}');",
+		},
+		{
+			name: "no roles",
+			args: args{login: "user", hash: "$scrypt$ln=15,r=8,p=1$c2FsdA$a2V5", roles: nil},
+			want: "INSERT INTO users(login, password, roles)\nVALUES ('user', '$scrypt$ln=15,r=8,p=1$c2FsdA$a2V5', '{}');",
+		},
+		{
+			name: "quote in login",
+			args: args{login: "o'brien'); DROP TABLE users; --", hash: "hash", roles: []string{"USER"}},
+			want: "INSERT INTO users(login, password, roles)\nVALUES ('o''brien''); DROP TABLE users; --', 'hash', '{\"USER\"}');",
+		},
+	}
+
+	for _, tt := range tests {
+		if got := insertStatement(tt.args.login, tt.args.hash, tt.args.roles); got != tt.want {
+			t.Errorf("%s: got\n%v\nwant\n%v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/netology-code/remux/pkg/remux"
+	"log"
+	"net/http"
+	"service/cmd/service/app/dto"
+	"service/pkg/security"
+)
+
+// Выпуск API-ключа текущего пользователя
+func (s *Server) createAPIKey(writer http.ResponseWriter, request *http.Request) {
+	details, ok := currentUser(request)
+	if !ok {
+		writer.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	var data dto.NewAPIKeyDTO
+	err := json.NewDecoder(request.Body).Decode(&data)
+	if err != nil {
+		writeProblem(writer, http.StatusBadRequest, "body must be {\"name\": \"...\", \"roles\": [...]}")
+		return
+	}
+
+	key, apiKey, err := s.securitySvc.CreateAPIKey(request.Context(), details.ID, data.Name, data.Roles)
+	if err != nil {
+		switch {
+		case errors.Is(err, security.ErrInvalidKeyName), errors.Is(err, security.ErrInvalidKeyScope):
+			writeProblem(writer, http.StatusBadRequest, err.Error())
+		default:
+			log.Print(err)
+			writer.WriteHeader(http.StatusInternalServerError)
+		}
+		return
+	}
+
+	respBody, err := json.Marshal(apiKeyDTO(apiKey, key))
+	if err != nil {
+		writer.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	writer.Header().Set("Content-Type", "application/json")
+	// ключ показывается один раз и не должен оседать в кэшах
+	writer.Header().Set("Cache-Control", "no-store")
+	writer.WriteHeader(http.StatusCreated)
+	_, err = writer.Write(respBody)
+	if err != nil {
+		log.Print(err)
+	}
+}
+
+// Список API-ключей текущего пользователя
+func (s *Server) apiKeys(writer http.ResponseWriter, request *http.Request) {
+	details, ok := currentUser(request)
+	if !ok {
+		writer.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	keys, err := s.securitySvc.APIKeys(request.Context(), details.ID)
+	if err != nil {
+		log.Print(err)
+		writer.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	data := make([]*dto.APIKeyDTO, 0, len(keys))
+	for _, key := range keys {
+		data = append(data, apiKeyDTO(key, ""))
+	}
+	writeJSON(writer, data)
+}
+
+// Отзыв API-ключа текущего пользователя
+func (s *Server) revokeAPIKey(writer http.ResponseWriter, request *http.Request) {
+	details, ok := currentUser(request)
+	if !ok {
+		writer.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	keyID, err := remux.ParamInt64(request.Context(), "id")
+	if err != nil {
+		remux.WriteParamError(writer, err)
+		return
+	}
+
+	err = s.securitySvc.RevokeAPIKey(request.Context(), details.ID, keyID)
+	if err != nil {
+		switch {
+		case errors.Is(err, security.ErrAPIKeyNotFound):
+			writer.WriteHeader(http.StatusNotFound)
+		default:
+			log.Print(err)
+			writer.WriteHeader(http.StatusInternalServerError)
+		}
+		return
+	}
+	s.detailsCache.InvalidateIf(func(profile interface{}) bool {
+		details, ok := profile.(*security.UserDetails)
+		return ok && details.APIKeyID == keyID
+	})
+
+	writer.WriteHeader(http.StatusNoContent)
+}
+
+// sessionOnly пропускает только пользователей, вошедших не по API-ключу (см. registerSessionRoutes)
+func sessionOnly(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		details, ok := currentUser(request)
+		if !ok {
+			writer.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if details.APIKeyID != 0 {
+			writeProblem(writer, http.StatusForbidden, "not allowed with an api key")
+			return
+		}
+		handler.ServeHTTP(writer, request)
+	})
+}
+
+func apiKeyDTO(key *security.APIKey, secret string) *dto.APIKeyDTO {
+	return &dto.APIKeyDTO{
+		ID:       key.ID,
+		Name:     key.Name,
+		Prefix:   key.Prefix,
+		Roles:    key.Roles,
+		Created:  key.Created,
+		LastUsed: key.LastUsed,
+		Key:      secret,
+	}
+}
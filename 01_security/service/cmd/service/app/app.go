@@ -3,60 +3,303 @@ package app
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"github.com/netology-code/remux/pkg/middleware/authenticator"
 	"github.com/netology-code/remux/pkg/middleware/authorizator"
+	ipidentificator "github.com/netology-code/remux/pkg/middleware/identificator"
 	"github.com/netology-code/remux/pkg/middleware/logger"
+	"github.com/netology-code/remux/pkg/middleware/recoverer"
+	"github.com/netology-code/remux/pkg/middleware/requestid"
 	"github.com/netology-code/remux/pkg/remux"
 	"log"
+	"math"
 	"net/http"
 	"service/cmd/service/app/dto"
 	"service/cmd/service/app/middleware/identificator"
+	"service/pkg/audit"
 	"service/pkg/business"
 	"service/pkg/security"
+	"strconv"
+	"time"
 )
 
+const (
+	// sessionCookie - cookie, в которой браузерные клиенты передают токен
+	sessionCookie = "session"
+	apiKeyHeader  = "X-API-Key"
+	apiKeyQuery   = "api_key"
+	realm         = "service"
+	// retryAfter - через сколько секунд клиенту стоит повторить запрос, если БД недоступна
+	retryAfter = 5
+
+	detailsCacheTTL         = 30 * time.Second
+	detailsCacheNegativeTTL = 5 * time.Second
+	detailsCacheSize        = 10_000
+
+	// maxAuditLimit - сколько записей журнала аудита можно запросить за раз
+	maxAuditLimit = 1000
+)
+
+// permissions - разрешения, которые дают роли (используются в политиках через permission(...))
+var permissions = authorizator.Permissions{
+	security.RoleAdmin: {"*"},
+	security.RoleUser:  {"profile:read"},
+}
+
 type Server struct {
 	securitySvc *security.Service
 	businessSvc *business.Service
+	auditLog    *audit.Log
 	mux         *remux.ReMux
+	policies    *authorizator.Policies
+	// detailsCache - кэш профилей по токену, после выхода или смены ролей записи нужно удалять
+	detailsCache *authenticator.Cache
 }
 
-func NewServer(securitySvc *security.Service, businessSvc *business.Service, mux *remux.ReMux) *Server {
-	return &Server{securitySvc: securitySvc, businessSvc: businessSvc, mux: mux}
+func NewServer(securitySvc *security.Service, businessSvc *business.Service, auditLog *audit.Log, mux *remux.ReMux, policies *authorizator.Policies) *Server {
+	return &Server{securitySvc: securitySvc, businessSvc: businessSvc, auditLog: auditLog, mux: mux, policies: policies}
 }
 
 func (s *Server) Init() error {
-	logMd := logger.Logger
-	identificatorMd := identificator.Identificator
-	authenticatorMd := authenticator.Authenticator(identificator.Identifier, s.securitySvc.UserDetails)
+	logMd := logger.New(logger.Config{
+		Route: remux.RoutePattern,
+		UserID: func(ctx context.Context) (string, error) {
+			userDetails, err := authenticator.Authentication(ctx)
+			if err != nil {
+				return "", err
+			}
+			details, ok := userDetails.(*security.UserDetails)
+			if !ok {
+				return "", authenticator.ErrNoAuthentication
+			}
+			return strconv.FormatInt(details.ID, 10), nil
+		},
+	})
+	// схемы пробуются в указанном порядке, Authenticator выбирает способ проверки по найденной схеме
+	identificatorMd := identificator.New(
+		identificator.Bearer(),
+		identificator.Cookie(sessionCookie),
+		identificator.Basic(),
+		identificator.APIKeyHeader(apiKeyHeader),
+		identificator.APIKeyQuery(apiKeyQuery),
+	)
+	// Basic не кэшируется: иначе пароль проверялся бы только при первом запросе
+	s.detailsCache = authenticator.NewCache(userDetails(s.securitySvc.UserDetails), authenticator.CacheConfig{
+		TTL:         detailsCacheTTL,
+		NegativeTTL: detailsCacheNegativeTTL,
+		MaxSize:     detailsCacheSize,
+		// профиль не живёт в кэше дольше токена доступа
+		Expires: func(profile interface{}) time.Time {
+			if details, ok := profile.(*security.UserDetails); ok {
+				return details.Expire
+			}
+			return time.Time{}
+		},
+	})
+	authenticatorMd := authenticator.New(authenticator.Config{
+		Identifier: identificator.Identifier,
+		UserDetails: authenticator.ByScheme(identificator.Scheme, map[string]authenticator.UserDetailsFunc{
+			identificator.SchemeBearer: s.detailsCache.UserDetails,
+			identificator.SchemeCookie: s.detailsCache.UserDetails,
+			identificator.SchemeAPIKey: s.detailsCache.UserDetails,
+			identificator.SchemeBasic: userDetails(func(ctx context.Context, id *string) (interface{}, error) {
+				credentials, err := identificator.CredentialsFrom(ctx)
+				if err != nil {
+					return nil, err
+				}
+				return s.securitySvc.UserDetailsByPassword(ctx, credentials.Token, credentials.Password, clientIP(ctx))
+			}),
+		}),
+		Realm:      realm,
+		Challenges: []string{identificator.SchemeBearer, identificator.SchemeBasic},
+		RetryAfter: retryAfter,
+		OnError:    s.authenticationError,
+	})
 
 	// функция-связка между middleware и security service (для чистоты security service, который ничего не знает об http)
-	roleChecker := func(ctx context.Context, roles ...string) bool {
-		userDetails, err := authenticator.Authentication(ctx)
-		if err != nil {
-			return false
-		}
-		return s.securitySvc.HasAnyRole(ctx, userDetails, roles...)
+	authz := authorizator.New(authorizator.Config{
+		Subject: func(ctx context.Context) (*authorizator.Subject, error) {
+			userDetails, err := authenticator.Authentication(ctx)
+			if err != nil {
+				return nil, err
+			}
+			details, ok := userDetails.(*security.UserDetails)
+			if !ok {
+				return nil, authenticator.ErrNoAuthentication
+			}
+			// роли API-ключа ограничивают роли владельца
+			return &authorizator.Subject{ID: strconv.FormatInt(details.ID, 10), Roles: details.Roles, Scopes: details.Scopes}, nil
+		},
+		Permissions: permissions,
+		Param:       remux.Param,
+		OnDenied:    s.accessDenied,
+	})
+	adminMd, err := s.policy(authz, "admin")
+	if err != nil {
+		return err
 	}
-	adminRoleMd := authorizator.Authorizator(roleChecker, security.RoleAdmin)
-	userRoleMd := authorizator.Authorizator(roleChecker, security.RoleUser)
+	userMd, err := s.policy(authz, "user")
+	if err != nil {
+		return err
+	}
+	debugRoutesMd, err := s.policy(authz, "debug.routes")
+	if err != nil {
+		return err
+	}
+	usersRolesMd, err := s.policy(authz, "users.roles")
+	if err != nil {
+		return err
+	}
+	auditReadMd, err := s.policy(authz, "audit.read")
+	if err != nil {
+		return err
+	}
+
+	// ipidentificator определяет IP-адрес клиента: для журнала доступа и ограничения попыток входа
+	s.mux.Use(requestid.RequestID, ipidentificator.Identificator, logMd, recoverer.Recoverer, s.auditActor)
 
-	if err := s.mux.RegisterPlain(remux.POST, "/login", http.HandlerFunc(s.login), logMd); err != nil {
+	if err := s.mux.RegisterPlain(remux.POST, "/login", http.HandlerFunc(s.login)); err != nil {
+		return err
+	}
+	if err := s.mux.RegisterPlain(remux.POST, "/login/mfa", http.HandlerFunc(s.loginMFA)); err != nil {
+		return err
+	}
+	if err := s.mux.RegisterPlain(remux.POST, "/register", http.HandlerFunc(s.register)); err != nil {
+		return err
+	}
+	if err := s.mux.RegisterPlain(remux.POST, "/token/refresh", http.HandlerFunc(s.refresh)); err != nil {
+		return err
+	}
+	if err := s.mux.RegisterPlain(remux.GET, "/public", http.HandlerFunc(s.public)); err != nil {
+		return err
+	}
+
+	// маршруты, требующие аутентификации (middleware выполняются в порядке объявления, см. remux.OrderDeclaration)
+	// logger.Capture - чтобы в журнал доступа попал пользователь, определённый authenticator'ом
+	secured, err := s.mux.Group("", identificatorMd, authenticatorMd, logger.Capture, s.auditActor)
+	if err != nil {
+		return err
+	}
+	if err := secured.RegisterPlain(remux.GET, "/admin", http.HandlerFunc(s.admin), adminMd); err != nil {
+		return err
+	}
+	if err := secured.RegisterPlain(remux.GET, "/user", http.HandlerFunc(s.user), userMd); err != nil {
 		return err
 	}
-	if err := s.mux.RegisterPlain(remux.GET, "/public", http.HandlerFunc(s.public), logMd); err != nil {
+	if err := secured.RegisterPlain(remux.POST, "/logout", http.HandlerFunc(s.logout)); err != nil {
 		return err
 	}
-	if err := s.mux.RegisterPlain(remux.GET, "/admin", http.HandlerFunc(s.admin), adminRoleMd, authenticatorMd, identificatorMd, logMd); err != nil {
+	if err := s.registerSessionRoutes(secured); err != nil {
 		return err
 	}
-	if err := s.mux.RegisterPlain(remux.GET, "/user", http.HandlerFunc(s.user), userRoleMd, authenticatorMd, identificatorMd, logMd); err != nil {
+	if err := secured.RegisterPattern(remux.PUT, "/admin/users/{id:int}/roles", http.HandlerFunc(s.setRoles), usersRolesMd); err != nil {
+		return err
+	}
+	if err := secured.RegisterPlain(remux.GET, "/admin/audit", http.HandlerFunc(s.auditEntries), auditReadMd); err != nil {
+		return err
+	}
+	if err := secured.RegisterPlain(remux.GET, "/admin/audit/verify", http.HandlerFunc(s.verifyAudit), auditReadMd); err != nil {
+		return err
+	}
+	if err := secured.RegisterPlain(remux.GET, "/debug/routes", http.HandlerFunc(s.routes), debugRoutesMd); err != nil {
 		return err
 	}
 
 	return nil
 }
 
+// registerSessionRoutes регистрирует маршруты управления учётной записью: они доступны только в сессии,
+// иначе украденный API-ключ (даже с урезанными ролями) позволял бы перехватить учётную запись владельца
+func (s *Server) registerSessionRoutes(secured *remux.Group) error {
+	session, err := secured.Group("", sessionOnly)
+	if err != nil {
+		return err
+	}
+	if err := session.RegisterPlain(remux.POST, "/logout/all", http.HandlerFunc(s.logoutAll)); err != nil {
+		return err
+	}
+	if err := session.RegisterPlain(remux.POST, "/password", http.HandlerFunc(s.changePassword)); err != nil {
+		return err
+	}
+	if err := session.RegisterPlain(remux.POST, "/mfa/totp", http.HandlerFunc(s.enrollTOTP)); err != nil {
+		return err
+	}
+	if err := session.RegisterPlain(remux.POST, "/mfa/totp/confirm", http.HandlerFunc(s.confirmTOTP)); err != nil {
+		return err
+	}
+	if err := session.RegisterPlain(remux.POST, "/mfa/totp/disable", http.HandlerFunc(s.disableTOTP)); err != nil {
+		return err
+	}
+	if err := session.RegisterPlain(remux.GET, "/api-keys", http.HandlerFunc(s.apiKeys)); err != nil {
+		return err
+	}
+	if err := session.RegisterPlain(remux.POST, "/api-keys", http.HandlerFunc(s.createAPIKey)); err != nil {
+		return err
+	}
+	if err := session.RegisterPattern(remux.DELETE, "/api-keys/{id:int}", http.HandlerFunc(s.revokeAPIKey)); err != nil {
+		return err
+	}
+	return nil
+}
+
+// policy возвращает middleware, проверяющее доступ по политике name из файла политик
+func (s *Server) policy(authz *authorizator.Authorizer, name string) (func(http.Handler) http.Handler, error) {
+	check, err := authz.Policy(s.policies, name)
+	if err != nil {
+		return nil, err
+	}
+	return authz.Require(check), nil
+}
+
+// userDetails переводит ошибки security service в ошибки authenticator:
+// неизвестный или просроченный токен, неверный пароль, пароль без второго фактора - 401, остальное (ошибки БД) - 503
+func userDetails(details authenticator.UserDetailsFunc) authenticator.UserDetailsFunc {
+	return func(ctx context.Context, id *string) (interface{}, error) {
+		profile, err := details(ctx, id)
+		if err != nil {
+			if errors.Is(err, security.ErrTokenExpired) {
+				return nil, fmt.Errorf("%w: %v", authenticator.ErrExpiredCredentials, err)
+			}
+			if errors.Is(err, security.ErrUserNotFound) || errors.Is(err, security.ErrInvalidPassword) ||
+				errors.Is(err, security.ErrTooManyAttempts) || errors.Is(err, security.ErrMFARequired) ||
+				errors.Is(err, identificator.ErrNoIdentifier) {
+				return nil, fmt.Errorf("%w: %v", authenticator.ErrInvalidCredentials, err)
+			}
+			return nil, fmt.Errorf("%w: %v", authenticator.ErrUnavailable, err)
+		}
+		return profile, nil
+	}
+}
+
+// clientIP возвращает IP-адрес клиента (пустая строка - адрес неизвестен)
+func clientIP(ctx context.Context) string {
+	addr, err := ipidentificator.Addr(ctx)
+	if err != nil {
+		return ""
+	}
+	return addr.String()
+}
+
+// authenticationError отвечает на ошибку аутентификации телом application/problem+json,
+// отказы (401) записываются в журнал аудита
+func (s *Server) authenticationError(writer http.ResponseWriter, request *http.Request, status int, err error) {
+	if status != http.StatusUnauthorized {
+		log.Printf("authentication failed: %v", err)
+	} else if credentials, credErr := identificator.CredentialsFrom(request.Context()); credErr == nil {
+		// без учётных данных запрос просто анонимный, записывать нечего
+		entry := audit.Entry{
+			Action:  audit.ActionAuthenticationFail,
+			Details: map[string]string{"scheme": credentials.Scheme, "error": err.Error()},
+		}
+		if credentials.Scheme == identificator.SchemeBasic {
+			entry.Login = credentials.Token
+		}
+		s.record(request, entry)
+	}
+	writeProblem(writer, status, "")
+}
+
 func (s *Server) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
 	s.mux.ServeHTTP(writer, request)
 }
@@ -79,13 +322,125 @@ func (s *Server) login(writer http.ResponseWriter, request *http.Request) {
 		return
 	}
 
-	token, err := s.securitySvc.Login(request.Context(), login, password)
+	tokens, err := s.securitySvc.Login(request.Context(), login, password, clientIP(request.Context()))
+	if err != nil {
+		if writeThrottled(writer, err) {
+			return
+		}
+		if errors.Is(err, security.ErrUserNotFound) || errors.Is(err, security.ErrInvalidPassword) {
+			writer.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		log.Print(err)
+		writer.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if tokens.MFAToken != "" {
+		writeMFARequired(writer, tokens)
+		return
+	}
+
+	writeTokens(writer, tokens)
+}
+
+// Обмен токена обновления на новую пару токенов
+func (s *Server) refresh(writer http.ResponseWriter, request *http.Request) {
+	err := request.ParseForm()
 	if err != nil {
 		writer.WriteHeader(http.StatusBadRequest)
 		return
 	}
 
-	data := &dto.TokenDTO{Token: token}
+	refreshToken := request.PostForm.Get("refreshToken")
+	if refreshToken == "" {
+		writer.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	tokens, err := s.securitySvc.Refresh(request.Context(), refreshToken)
+	if err != nil {
+		var reuse *security.TokenReuseError
+		if errors.As(err, &reuse) {
+			log.Printf("refresh token reuse detected, session revoked")
+			// токены доступа отозванной сессии не должны действовать и из кэша
+			s.invalidateUser(reuse.UserID)
+		}
+		if errors.Is(err, security.ErrInvalidRefreshToken) || errors.Is(err, security.ErrRefreshTokenExpired) || errors.Is(err, security.ErrRefreshTokenReused) {
+			writer.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		log.Print(err)
+		writer.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	writeTokens(writer, tokens)
+}
+
+// Завершение текущей сессии
+func (s *Server) logout(writer http.ResponseWriter, request *http.Request) {
+	credentials, err := identificator.CredentialsFrom(request.Context())
+	if err != nil || credentials.Scheme == identificator.SchemeBasic || credentials.Scheme == identificator.SchemeAPIKey {
+		// при Basic-аутентификации и по API-ключу сессии нет
+		writer.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	err = s.securitySvc.Logout(request.Context(), credentials.Token)
+	if err != nil && !errors.Is(err, security.ErrUserNotFound) {
+		log.Print(err)
+		writer.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	s.detailsCache.Invalidate(credentials.Token)
+
+	writer.WriteHeader(http.StatusNoContent)
+}
+
+// Завершение всех сессий текущего пользователя
+func (s *Server) logoutAll(writer http.ResponseWriter, request *http.Request) {
+	details, ok := currentUser(request)
+	if !ok {
+		writer.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	err := s.securitySvc.LogoutAll(request.Context(), details.ID)
+	if err != nil {
+		log.Print(err)
+		writer.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	s.invalidateUser(details.ID)
+
+	writer.WriteHeader(http.StatusNoContent)
+}
+
+// invalidateUser удаляет из кэша профили всех сессий пользователя
+func (s *Server) invalidateUser(userID int64) {
+	s.detailsCache.InvalidateIf(func(profile interface{}) bool {
+		details, ok := profile.(*security.UserDetails)
+		return ok && details.ID == userID
+	})
+}
+
+// writeThrottled отвечает 429 с Retry-After, если попытка отклонена из-за ограничения на перебор
+func writeThrottled(writer http.ResponseWriter, err error) bool {
+	var throttled *security.ThrottledError
+	if !errors.As(err, &throttled) {
+		return false
+	}
+	writer.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(throttled.RetryAfter.Seconds()))))
+	writer.WriteHeader(http.StatusTooManyRequests)
+	return true
+}
+
+func writeTokens(writer http.ResponseWriter, tokens *security.Tokens) {
+	data := &dto.TokenDTO{
+		Token:        tokens.Access,
+		RefreshToken: tokens.Refresh,
+		ExpiresIn:    int64(tokens.ExpiresIn / time.Second),
+	}
 	respBody, err := json.Marshal(data)
 	if err != nil {
 		writer.WriteHeader(http.StatusInternalServerError)
@@ -93,6 +448,8 @@ func (s *Server) login(writer http.ResponseWriter, request *http.Request) {
 	}
 
 	writer.Header().Set("Content-Type", "application/json")
+	// токены не должны оседать в кэшах (RFC 6749, раздел 5.1)
+	writer.Header().Set("Cache-Control", "no-store")
 	_, err = writer.Write(respBody)
 	if err != nil {
 		log.Print(err)
@@ -113,3 +470,29 @@ func (s *Server) admin(writer http.ResponseWriter, request *http.Request) {
 func (s *Server) user(writer http.ResponseWriter, request *http.Request) {
 	writer.Write([]byte("user"))
 }
+
+// Список зарегистрированных маршрутов (только для ADMIN)
+func (s *Server) routes(writer http.ResponseWriter, request *http.Request) {
+	routes := s.mux.Routes()
+	data := make([]*dto.RouteDTO, 0, len(routes))
+	for _, route := range routes {
+		data = append(data, &dto.RouteDTO{
+			Method:      string(route.Method),
+			Pattern:     route.Pattern,
+			Name:        route.Name,
+			Middlewares: route.Middlewares,
+		})
+	}
+
+	respBody, err := json.Marshal(data)
+	if err != nil {
+		writer.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	writer.Header().Set("Content-Type", "application/json")
+	_, err = writer.Write(respBody)
+	if err != nil {
+		log.Print(err)
+	}
+}
@@ -0,0 +1,63 @@
+package app
+
+import (
+	"context"
+	"github.com/netology-code/remux/pkg/middleware/authenticator"
+	"github.com/netology-code/remux/pkg/remux"
+	"net/http"
+	"net/http/httptest"
+	"service/pkg/security"
+	"testing"
+)
+
+func TestSessionRoutes_APIKey(t *testing.T) {
+	mux := remux.NewReMux()
+	// пользователь вошёл по API-ключу с ролями только на чтение
+	authenticatorMd := authenticator.Authenticator(
+		func(ctx context.Context) (*string, error) {
+			key := "sk_prefix_secret"
+			return &key, nil
+		},
+		func(ctx context.Context, id *string) (interface{}, error) {
+			return &security.UserDetails{ID: 1, Roles: []string{security.RoleUser}, APIKeyID: 1, Scopes: []string{security.RoleUser}}, nil
+		},
+	)
+	secured, err := mux.Group("", authenticatorMd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// сервисы не нужны: до обработчиков запросы не доходят
+	server := &Server{}
+	if err := server.registerSessionRoutes(secured); err != nil {
+		t.Fatal(err)
+	}
+
+	type args struct {
+		method remux.Method
+		path   string
+	}
+
+	tests := []struct {
+		name string
+		args args
+		want int
+	}{
+		{name: "logout all", args: args{method: remux.POST, path: "/logout/all"}, want: http.StatusForbidden},
+		{name: "change password", args: args{method: remux.POST, path: "/password"}, want: http.StatusForbidden},
+		{name: "enroll totp", args: args{method: remux.POST, path: "/mfa/totp"}, want: http.StatusForbidden},
+		{name: "confirm totp", args: args{method: remux.POST, path: "/mfa/totp/confirm"}, want: http.StatusForbidden},
+		{name: "disable totp", args: args{method: remux.POST, path: "/mfa/totp/disable"}, want: http.StatusForbidden},
+		{name: "list api keys", args: args{method: remux.GET, path: "/api-keys"}, want: http.StatusForbidden},
+		{name: "create api key", args: args{method: remux.POST, path: "/api-keys"}, want: http.StatusForbidden},
+		{name: "revoke api key", args: args{method: remux.DELETE, path: "/api-keys/1"}, want: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		request := httptest.NewRequest(string(tt.args.method), tt.args.path, nil)
+		response := httptest.NewRecorder()
+		mux.ServeHTTP(response, request)
+		if got := response.Code; got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
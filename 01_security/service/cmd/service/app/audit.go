@@ -0,0 +1,145 @@
+package app
+
+import (
+	"errors"
+	"github.com/netology-code/remux/pkg/middleware/requestid"
+	"github.com/netology-code/remux/pkg/remux"
+	"log"
+	"net/http"
+	"service/cmd/service/app/dto"
+	"service/pkg/audit"
+	"strconv"
+	"time"
+)
+
+// Записи журнала аудита (только для ADMIN): ?user=<id>&action=<действие>&from=<RFC 3339>&to=<RFC 3339>&limit=<n>
+func (s *Server) auditEntries(writer http.ResponseWriter, request *http.Request) {
+	query := request.URL.Query()
+	filter := audit.Filter{Action: query.Get("action")}
+
+	var err error
+	if value := query.Get("user"); value != "" {
+		filter.UserID, err = strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			writeProblem(writer, http.StatusBadRequest, "user must be an integer")
+			return
+		}
+	}
+	if value := query.Get("from"); value != "" {
+		filter.From, err = time.Parse(time.RFC3339, value)
+		if err != nil {
+			writeProblem(writer, http.StatusBadRequest, "from must be RFC 3339 time")
+			return
+		}
+	}
+	if value := query.Get("to"); value != "" {
+		filter.To, err = time.Parse(time.RFC3339, value)
+		if err != nil {
+			writeProblem(writer, http.StatusBadRequest, "to must be RFC 3339 time")
+			return
+		}
+	}
+	if value := query.Get("limit"); value != "" {
+		filter.Limit, err = strconv.Atoi(value)
+		if err != nil || filter.Limit <= 0 || filter.Limit > maxAuditLimit {
+			writeProblem(writer, http.StatusBadRequest, "limit must be in [1, "+strconv.Itoa(maxAuditLimit)+"]")
+			return
+		}
+	}
+
+	entries, err := s.auditLog.Query(request.Context(), filter)
+	if err != nil {
+		log.Print(err)
+		writer.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	data := make([]*dto.AuditEntryDTO, 0, len(entries))
+	for _, entry := range entries {
+		data = append(data, &dto.AuditEntryDTO{
+			ID:        entry.ID,
+			Time:      entry.Time,
+			Action:    entry.Action,
+			UserID:    entry.UserID,
+			ActorID:   entry.ActorID,
+			Login:     entry.Login,
+			IP:        entry.IP,
+			RequestID: entry.RequestID,
+			Details:   entry.Details,
+			Hash:      entry.Hash,
+		})
+	}
+	writeJSON(writer, data)
+}
+
+// Проверка цепочки хэшей журнала аудита (только для ADMIN)
+func (s *Server) verifyAudit(writer http.ResponseWriter, request *http.Request) {
+	checked, err := s.auditLog.Verify(request.Context())
+	data := &dto.AuditVerifyDTO{Valid: err == nil, Checked: checked}
+	if err != nil {
+		var broken *audit.ChainError
+		if !errors.As(err, &broken) {
+			log.Print(err)
+			writer.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		log.Print(err)
+		data.BrokenAt = broken.ID
+	}
+	head, err := s.auditLog.Head(request.Context())
+	if err != nil {
+		log.Print(err)
+		writer.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	data.HeadID = head.ID
+	data.HeadHash = head.Hash
+	writeJSON(writer, data)
+}
+
+// auditActor кладёт в контекст исполнителя запроса для записей журнала аудита (в том числе из security service).
+// Подключается дважды: для всех запросов и после authenticator'а, чтобы учесть пользователя.
+func (s *Server) auditActor(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		ctx := request.Context()
+		actor := &audit.Actor{IP: clientIP(ctx)}
+		actor.RequestID, _ = requestid.RequestIDFrom(ctx)
+		if details, ok := currentUser(request); ok {
+			actor.UserID = details.ID
+		}
+
+		handler.ServeHTTP(writer, request.WithContext(audit.WithActor(ctx, actor)))
+	})
+}
+
+// accessDenied записывает отказ authorizator'а в журнал аудита и отвечает 403
+func (s *Server) accessDenied(writer http.ResponseWriter, request *http.Request) {
+	details := map[string]string{"method": request.Method, "path": request.URL.Path}
+	if pattern, err := remux.RoutePattern(request.Context()); err == nil {
+		details["route"] = pattern
+	}
+	entry := audit.Entry{Action: audit.ActionAccessDenied, Details: details}
+	if user, ok := currentUser(request); ok {
+		entry.UserID = user.ID
+		entry.Login = user.Login
+		if user.APIKeyID != 0 {
+			details["apiKey"] = strconv.FormatInt(user.APIKeyID, 10)
+		}
+	}
+	s.record(request, entry)
+
+	writeProblem(writer, http.StatusForbidden, "")
+}
+
+// record пишет запись в журнал аудита, ошибка записи на ответ не влияет
+func (s *Server) record(request *http.Request, entry audit.Entry) {
+	if entry.IP == "" {
+		entry.IP = clientIP(request.Context())
+	}
+	if entry.RequestID == "" {
+		entry.RequestID, _ = requestid.RequestIDFrom(request.Context())
+	}
+	if err := s.auditLog.Record(request.Context(), entry); err != nil {
+		log.Printf("can't record audit entry %s: %v", entry.Action, err)
+	}
+}
@@ -0,0 +1,20 @@
+package dto
+
+import "time"
+
+// NewAPIKeyDTO - запрос на выпуск ключа, Roles = nil - все роли владельца
+type NewAPIKeyDTO struct {
+	Name  string   `json:"name"`
+	Roles []string `json:"roles"`
+}
+
+type APIKeyDTO struct {
+	ID       int64      `json:"id"`
+	Name     string     `json:"name"`
+	Prefix   string     `json:"prefix"`
+	Roles    []string   `json:"roles"`
+	Created  time.Time  `json:"created"`
+	LastUsed *time.Time `json:"lastUsed,omitempty"`
+	// Key заполняется только в ответе на выпуск ключа
+	Key string `json:"key,omitempty"`
+}
@@ -0,0 +1,26 @@
+package dto
+
+import "time"
+
+type AuditEntryDTO struct {
+	ID        int64             `json:"id"`
+	Time      time.Time         `json:"time"`
+	Action    string            `json:"action"`
+	UserID    int64             `json:"userId,omitempty"`
+	ActorID   int64             `json:"actorId,omitempty"`
+	Login     string            `json:"login,omitempty"`
+	IP        string            `json:"ip,omitempty"`
+	RequestID string            `json:"requestId,omitempty"`
+	Details   map[string]string `json:"details,omitempty"`
+	Hash      string            `json:"hash"`
+}
+
+// AuditVerifyDTO - результат проверки цепочки журнала, BrokenAt - первая подменённая запись.
+// HeadID и HeadHash - голова цепочки для сверки с копией вне БД.
+type AuditVerifyDTO struct {
+	Valid    bool   `json:"valid"`
+	Checked  int64  `json:"checked"`
+	BrokenAt int64  `json:"brokenAt,omitempty"`
+	HeadID   int64  `json:"headId"`
+	HeadHash string `json:"headHash"`
+}
@@ -0,0 +1,18 @@
+package dto
+
+// MFARequiredDTO - ответ на вход с паролем, если включена двухфакторная аутентификация:
+// MFAToken вместе с кодом нужно отправить на /login/mfa
+type MFARequiredDTO struct {
+	MFAToken string `json:"mfaToken"`
+	// ExpiresIn - время жизни mfa-токена в секундах
+	ExpiresIn int64 `json:"expiresIn"`
+}
+
+type TOTPEnrollmentDTO struct {
+	Secret string `json:"secret"`
+	URI    string `json:"uri"`
+}
+
+type RecoveryCodesDTO struct {
+	RecoveryCodes []string `json:"recoveryCodes"`
+}
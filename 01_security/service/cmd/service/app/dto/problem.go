@@ -0,0 +1,9 @@
+package dto
+
+// ProblemDTO - тело ответа об ошибке в формате RFC 7807 (application/problem+json)
+type ProblemDTO struct {
+	Type   string `json:"type"`
+	Title  string `json:"title"`
+	Status int    `json:"status"`
+	Detail string `json:"detail,omitempty"`
+}
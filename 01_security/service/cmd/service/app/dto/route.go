@@ -0,0 +1,8 @@
+package dto
+
+type RouteDTO struct {
+	Method      string   `json:"method"`
+	Pattern     string   `json:"pattern"`
+	Name        string   `json:"name,omitempty"`
+	Middlewares []string `json:"middlewares"`
+}
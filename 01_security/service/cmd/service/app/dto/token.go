@@ -1,5 +1,8 @@
 package dto
 
 type TokenDTO struct {
-	Token *string `json:"token"`
+	Token        string `json:"token"`
+	RefreshToken string `json:"refreshToken"`
+	// ExpiresIn - время жизни токена в секундах
+	ExpiresIn int64 `json:"expiresIn"`
 }
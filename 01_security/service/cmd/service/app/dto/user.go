@@ -0,0 +1,9 @@
+package dto
+
+type UserIDDTO struct {
+	ID int64 `json:"id"`
+}
+
+type RolesDTO struct {
+	Roles []string `json:"roles"`
+}
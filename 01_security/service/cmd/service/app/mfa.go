@@ -0,0 +1,158 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"service/cmd/service/app/dto"
+	"service/pkg/security"
+	"time"
+)
+
+// Второй шаг входа: mfa-токен из /login и код TOTP (или код восстановления) меняются на пару токенов
+func (s *Server) loginMFA(writer http.ResponseWriter, request *http.Request) {
+	err := request.ParseForm()
+	if err != nil {
+		writer.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	mfaToken := request.PostForm.Get("mfaToken")
+	code := request.PostForm.Get("code")
+	if mfaToken == "" || code == "" {
+		writer.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	tokens, err := s.securitySvc.LoginMFA(request.Context(), mfaToken, code)
+	if err != nil {
+		if writeThrottled(writer, err) {
+			return
+		}
+		switch {
+		case errors.Is(err, security.ErrInvalidMFAToken), errors.Is(err, security.ErrInvalidMFACode):
+			writeProblem(writer, http.StatusBadRequest, err.Error())
+		default:
+			log.Print(err)
+			writer.WriteHeader(http.StatusInternalServerError)
+		}
+		return
+	}
+
+	writeTokens(writer, tokens)
+}
+
+// Настройка TOTP: возвращает секрет и otpauth:// URI для приложения-аутентификатора
+func (s *Server) enrollTOTP(writer http.ResponseWriter, request *http.Request) {
+	details, ok := currentUser(request)
+	if !ok {
+		writer.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	enrollment, err := s.securitySvc.EnrollTOTP(request.Context(), details.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, security.ErrMFAAlreadyEnabled):
+			writeProblem(writer, http.StatusConflict, err.Error())
+		default:
+			log.Print(err)
+			writer.WriteHeader(http.StatusInternalServerError)
+		}
+		return
+	}
+
+	// секрет не должен оседать в кэшах
+	writer.Header().Set("Cache-Control", "no-store")
+	writeJSON(writer, &dto.TOTPEnrollmentDTO{Secret: enrollment.Secret, URI: enrollment.URI})
+}
+
+// Подтверждение настройки TOTP первым кодом из приложения: включает 2FA и возвращает коды восстановления
+func (s *Server) confirmTOTP(writer http.ResponseWriter, request *http.Request) {
+	details, ok := currentUser(request)
+	if !ok {
+		writer.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	err := request.ParseForm()
+	if err != nil {
+		writer.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	codes, err := s.securitySvc.ConfirmTOTP(request.Context(), details.ID, request.PostForm.Get("code"))
+	if err != nil {
+		switch {
+		case errors.Is(err, security.ErrMFAAlreadyEnabled):
+			writeProblem(writer, http.StatusConflict, err.Error())
+		case errors.Is(err, security.ErrMFANotEnrolled), errors.Is(err, security.ErrInvalidMFACode):
+			writeProblem(writer, http.StatusBadRequest, err.Error())
+		default:
+			log.Print(err)
+			writer.WriteHeader(http.StatusInternalServerError)
+		}
+		return
+	}
+	// в кэше профилей MFA ещё выключена
+	s.invalidateUser(details.ID)
+
+	writer.Header().Set("Cache-Control", "no-store")
+	writeJSON(writer, &dto.RecoveryCodesDTO{RecoveryCodes: codes})
+}
+
+// Отключение TOTP, требует текущий код или код восстановления
+func (s *Server) disableTOTP(writer http.ResponseWriter, request *http.Request) {
+	details, ok := currentUser(request)
+	if !ok {
+		writer.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	err := request.ParseForm()
+	if err != nil {
+		writer.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	err = s.securitySvc.DisableTOTP(request.Context(), details.ID, request.PostForm.Get("code"))
+	if err != nil {
+		if writeThrottled(writer, err) {
+			return
+		}
+		switch {
+		case errors.Is(err, security.ErrMFANotEnrolled), errors.Is(err, security.ErrInvalidMFACode):
+			writeProblem(writer, http.StatusBadRequest, err.Error())
+		default:
+			log.Print(err)
+			writer.WriteHeader(http.StatusInternalServerError)
+		}
+		return
+	}
+	s.invalidateUser(details.ID)
+
+	writer.WriteHeader(http.StatusNoContent)
+}
+
+func writeMFARequired(writer http.ResponseWriter, tokens *security.Tokens) {
+	writer.Header().Set("Cache-Control", "no-store")
+	writeJSON(writer, &dto.MFARequiredDTO{
+		MFAToken:  tokens.MFAToken,
+		ExpiresIn: int64(tokens.ExpiresIn / time.Second),
+	})
+}
+
+func writeJSON(writer http.ResponseWriter, data interface{}) {
+	respBody, err := json.Marshal(data)
+	if err != nil {
+		writer.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	writer.Header().Set("Content-Type", "application/json")
+	_, err = writer.Write(respBody)
+	if err != nil {
+		log.Print(err)
+	}
+}
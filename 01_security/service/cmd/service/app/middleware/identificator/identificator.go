@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"net/http"
+	"strings"
 )
 
 var ErrNoIdentifier = errors.New("no identifier")
@@ -18,24 +19,153 @@ func (c *contextKey) String() string {
 	return c.name
 }
 
+// Схемы, которыми клиент может передать учётные данные
+const (
+	SchemeBearer = "Bearer"
+	SchemeBasic  = "Basic"
+	SchemeCookie = "Cookie"
+	SchemeAPIKey = "APIKey"
+)
+
+// Credentials - учётные данные, найденные в запросе
+type Credentials struct {
+	// Scheme - схема, которой были переданы учётные данные (SchemeBearer, SchemeBasic, ...)
+	Scheme string
+	// Token - токен (для Basic - логин)
+	Token string
+	// Password заполняется только для Basic
+	Password string
+}
+
+// Extractor ищет учётные данные в запросе, ok = false - если их там нет или они некорректны
+type Extractor func(request *http.Request) (credentials *Credentials, ok bool)
+
+var defaultMiddleware = New(Bearer())
+
+// Identificator принимает токен из заголовка Authorization: Bearer <token>
 func Identificator(handler http.Handler) http.Handler {
-	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
-		// naive realization
-		token := request.Header.Get("Authorization")
-		if token != "" {
-			ctx := context.WithValue(request.Context(), identifierContextKey, &token)
-			request = request.WithContext(ctx)
-		}
+	return defaultMiddleware(handler)
+}
+
+// New создаёт middleware, которое пробует extractors по порядку и кладёт в контекст первые найденные учётные данные
+func New(extractors ...Extractor) func(http.Handler) http.Handler {
+	return func(handler http.Handler) http.Handler {
+		return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+			for _, extractor := range extractors {
+				if credentials, ok := extractor(request); ok {
+					ctx := context.WithValue(request.Context(), identifierContextKey, credentials)
+					request = request.WithContext(ctx)
+					break
+				}
+			}
 
-		handler.ServeHTTP(writer, request)
-	})
+			handler.ServeHTTP(writer, request)
+		})
+	}
 }
 
+// Identifier возвращает токен (для Basic - логин)
 func Identifier(ctx context.Context) (*string, error) {
-	value, ok := ctx.Value(identifierContextKey).(*string)
+	credentials, err := CredentialsFrom(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &credentials.Token, nil
+}
+
+// Scheme возвращает схему, которой были переданы учётные данные
+func Scheme(ctx context.Context) (string, error) {
+	credentials, err := CredentialsFrom(ctx)
+	if err != nil {
+		return "", err
+	}
+	return credentials.Scheme, nil
+}
+
+func CredentialsFrom(ctx context.Context) (*Credentials, error) {
+	value, ok := ctx.Value(identifierContextKey).(*Credentials)
 	if !ok {
 		return nil, ErrNoIdentifier
 	}
 	return value, nil
 }
 
+// Bearer - токен из заголовка Authorization: Bearer <token> (RFC 6750)
+func Bearer() Extractor {
+	return func(request *http.Request) (*Credentials, bool) {
+		token, ok := authorization(request, "Bearer")
+		if !ok || !isToken68(token) {
+			return nil, false
+		}
+		return &Credentials{Scheme: SchemeBearer, Token: token}, true
+	}
+}
+
+// Basic - логин и пароль из заголовка Authorization: Basic <base64> (RFC 7617)
+func Basic() Extractor {
+	return func(request *http.Request) (*Credentials, bool) {
+		login, password, ok := request.BasicAuth()
+		if !ok || login == "" {
+			return nil, false
+		}
+		return &Credentials{Scheme: SchemeBasic, Token: login, Password: password}, true
+	}
+}
+
+// Cookie - токен сессии из cookie name
+func Cookie(name string) Extractor {
+	return func(request *http.Request) (*Credentials, bool) {
+		cookie, err := request.Cookie(name)
+		if err != nil || cookie.Value == "" {
+			return nil, false
+		}
+		return &Credentials{Scheme: SchemeCookie, Token: cookie.Value}, true
+	}
+}
+
+// APIKeyHeader - ключ из заголовка name (обычно X-API-Key)
+func APIKeyHeader(name string) Extractor {
+	return func(request *http.Request) (*Credentials, bool) {
+		key := strings.TrimSpace(request.Header.Get(name))
+		if key == "" {
+			return nil, false
+		}
+		return &Credentials{Scheme: SchemeAPIKey, Token: key}, true
+	}
+}
+
+// APIKeyQuery - ключ из параметра строки запроса name.
+// Ключ в URL попадает в журналы прокси и историю браузера, поэтому этот вариант стоит ставить последним.
+func APIKeyQuery(name string) Extractor {
+	return func(request *http.Request) (*Credentials, bool) {
+		key := request.URL.Query().Get(name)
+		if key == "" {
+			return nil, false
+		}
+		return &Credentials{Scheme: SchemeAPIKey, Token: key}, true
+	}
+}
+
+// authorization возвращает параметры заголовка Authorization, если схема совпадает (без учёта регистра)
+func authorization(request *http.Request, scheme string) (string, bool) {
+	header := request.Header.Get("Authorization")
+	if len(header) <= len(scheme) || !strings.EqualFold(header[:len(scheme)], scheme) || header[len(scheme)] != ' ' {
+		return "", false
+	}
+	return strings.TrimLeft(header[len(scheme):], " "), true
+}
+
+// isToken68 проверяет синтаксис b64token из RFC 6750: 1*( ALPHA / DIGIT / "-" / "." / "_" / "~" / "+" / "/" ) *"="
+func isToken68(token string) bool {
+	trimmed := strings.TrimRight(token, "=")
+	if trimmed == "" {
+		return false
+	}
+	for i := 0; i < len(trimmed); i++ {
+		c := trimmed[i]
+		if !('a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || strings.IndexByte("-._~+/", c) != -1) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,149 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/netology-code/remux/pkg/middleware/authenticator"
+	"github.com/netology-code/remux/pkg/remux"
+	"log"
+	"net/http"
+	"service/cmd/service/app/dto"
+	"service/pkg/security"
+)
+
+// Регистрация нового пользователя (доступно всем)
+func (s *Server) register(writer http.ResponseWriter, request *http.Request) {
+	err := request.ParseForm()
+	if err != nil {
+		writer.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	login := request.PostForm.Get("login")
+	password := request.PostForm.Get("password")
+	userID, err := s.securitySvc.Register(request.Context(), login, password)
+	if err != nil {
+		switch {
+		case errors.Is(err, security.ErrInvalidLogin), errors.Is(err, security.ErrWeakPassword):
+			writeProblem(writer, http.StatusBadRequest, err.Error())
+		case errors.Is(err, security.ErrLoginTaken):
+			writeProblem(writer, http.StatusConflict, err.Error())
+		default:
+			log.Print(err)
+			writer.WriteHeader(http.StatusInternalServerError)
+		}
+		return
+	}
+
+	respBody, err := json.Marshal(&dto.UserIDDTO{ID: userID})
+	if err != nil {
+		writer.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	writer.Header().Set("Content-Type", "application/json")
+	writer.WriteHeader(http.StatusCreated)
+	_, err = writer.Write(respBody)
+	if err != nil {
+		log.Print(err)
+	}
+}
+
+// Смена пароля текущего пользователя, после смены все сессии завершаются
+func (s *Server) changePassword(writer http.ResponseWriter, request *http.Request) {
+	details, ok := currentUser(request)
+	if !ok {
+		writer.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	err := request.ParseForm()
+	if err != nil {
+		writer.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	oldPassword := request.PostForm.Get("oldPassword")
+	newPassword := request.PostForm.Get("newPassword")
+	err = s.securitySvc.ChangePassword(request.Context(), details.ID, oldPassword, newPassword)
+	if err != nil {
+		if writeThrottled(writer, err) {
+			return
+		}
+		switch {
+		case errors.Is(err, security.ErrInvalidPassword), errors.Is(err, security.ErrWeakPassword):
+			writeProblem(writer, http.StatusBadRequest, err.Error())
+		default:
+			log.Print(err)
+			writer.WriteHeader(http.StatusInternalServerError)
+		}
+		return
+	}
+	s.invalidateUser(details.ID)
+
+	writer.WriteHeader(http.StatusNoContent)
+}
+
+// Замена ролей пользователя (только для ADMIN)
+func (s *Server) setRoles(writer http.ResponseWriter, request *http.Request) {
+	userID, err := remux.ParamInt64(request.Context(), "id")
+	if err != nil {
+		remux.WriteParamError(writer, err)
+		return
+	}
+
+	var data dto.RolesDTO
+	err = json.NewDecoder(request.Body).Decode(&data)
+	if err != nil || data.Roles == nil {
+		writeProblem(writer, http.StatusBadRequest, "body must be {\"roles\": [...]}")
+		return
+	}
+
+	err = s.securitySvc.SetRoles(request.Context(), userID, data.Roles)
+	if err != nil {
+		switch {
+		case errors.Is(err, security.ErrUnknownRole):
+			writeProblem(writer, http.StatusBadRequest, err.Error())
+		case errors.Is(err, security.ErrUserNotFound):
+			writer.WriteHeader(http.StatusNotFound)
+		default:
+			log.Print(err)
+			writer.WriteHeader(http.StatusInternalServerError)
+		}
+		return
+	}
+	s.invalidateUser(userID)
+
+	writer.WriteHeader(http.StatusNoContent)
+}
+
+// currentUser возвращает профиль пользователя, определённый authenticator'ом
+func currentUser(request *http.Request) (*security.UserDetails, bool) {
+	userDetails, err := authenticator.Authentication(request.Context())
+	if err != nil {
+		return nil, false
+	}
+	details, ok := userDetails.(*security.UserDetails)
+	return details, ok
+}
+
+// writeProblem отвечает телом application/problem+json
+func writeProblem(writer http.ResponseWriter, status int, detail string) {
+	respBody, err := json.Marshal(&dto.ProblemDTO{
+		Type:   "about:blank",
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+	})
+	if err != nil {
+		writer.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	writer.Header().Set("Content-Type", "application/problem+json")
+	writer.WriteHeader(status)
+	_, err = writer.Write(respBody)
+	if err != nil {
+		log.Print(err)
+	}
+}
@@ -1,16 +1,141 @@
 -- табличка с пользователями и их паролями
+-- totp_secret - секрет TOTP (задаётся при настройке, действует после подтверждения: totp_enabled),
+-- totp_last_step - интервал последнего принятого кода (повторно код не принимается)
 CREATE TABLE users
 (
     id       BIGSERIAL PRIMARY KEY,
     login    TEXT      NOT NULL UNIQUE,
     password TEXT      NOT NULL,
     roles    TEXT[]    NOT NULL DEFAULT '{}',
+    totp_secret    TEXT,
+    totp_enabled   BOOLEAN NOT NULL DEFAULT FALSE,
+    totp_last_step BIGINT  NOT NULL DEFAULT 0,
     created  TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
 );
 
 -- табличка с токенами (чтобы пользователь каждый раз не присылал пароль и логин)
+-- family - сессия: токены, выданные при одном входе и последующих обновлениях
 CREATE TABLE tokens (
     id TEXT PRIMARY KEY,
     userId BIGINT NOT NULL REFERENCES users,
+    family TEXT NOT NULL,
+    expire TIMESTAMPTZ NOT NULL,
     created  TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
 );
+
+CREATE INDEX tokens_family_idx ON tokens (family);
+CREATE INDEX tokens_expire_idx ON tokens (expire);
+
+-- табличка с токенами обновления: used = TRUE - токен уже обменян на новую пару,
+-- повторное использование означает кражу токена и отзывает всю сессию
+CREATE TABLE refresh_tokens (
+    id TEXT PRIMARY KEY,
+    userId BIGINT NOT NULL REFERENCES users,
+    family TEXT NOT NULL,
+    used BOOLEAN NOT NULL DEFAULT FALSE,
+    expire TIMESTAMPTZ NOT NULL,
+    created  TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX refresh_tokens_family_idx ON refresh_tokens (family);
+CREATE INDEX refresh_tokens_expire_idx ON refresh_tokens (expire);
+
+-- счётчики неудачных попыток входа: key - "login:<логин>" или "ip:<адрес>"
+CREATE TABLE login_failures (
+    key TEXT PRIMARY KEY,
+    failures INT NOT NULL,
+    last_failure TIMESTAMPTZ NOT NULL,
+    blocked_until TIMESTAMPTZ NOT NULL
+);
+
+-- журнал попыток входа
+CREATE TABLE login_attempts (
+    id BIGSERIAL PRIMARY KEY,
+    login TEXT NOT NULL,
+    ip TEXT NOT NULL,
+    success BOOLEAN NOT NULL,
+    result TEXT NOT NULL,
+    created  TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX login_attempts_login_idx ON login_attempts (login, created);
+
+-- одноразовые коды восстановления для двухфакторной аутентификации (хранится только SHA-256)
+CREATE TABLE recovery_codes (
+    id BIGSERIAL PRIMARY KEY,
+    userId BIGINT NOT NULL REFERENCES users,
+    hash TEXT NOT NULL,
+    created  TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX recovery_codes_user_idx ON recovery_codes (userId);
+
+-- токены второго шага входа: выдаются после проверки пароля, обмениваются на пару токенов после проверки кода
+CREATE TABLE mfa_tokens (
+    id TEXT PRIMARY KEY,
+    userId BIGINT NOT NULL REFERENCES users,
+    attempts INT NOT NULL DEFAULT 0,
+    expire TIMESTAMPTZ NOT NULL,
+    created  TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- персональные API-ключи: prefix - открытая часть ключа (sk_xxxxxxxx), hash - SHA-256 всего ключа,
+-- roles - роли, которыми ограничен ключ (подмножество ролей владельца)
+CREATE TABLE api_keys (
+    id BIGSERIAL PRIMARY KEY,
+    userId BIGINT NOT NULL REFERENCES users,
+    name TEXT NOT NULL,
+    prefix TEXT NOT NULL UNIQUE,
+    hash TEXT NOT NULL,
+    roles TEXT[] NOT NULL DEFAULT '{}',
+    last_used TIMESTAMPTZ,
+    created  TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX api_keys_user_idx ON api_keys (userId);
+
+-- журнал аудита: только добавление (правила ниже отменяют UPDATE и DELETE, триггер запрещает TRUNCATE),
+-- hash - SHA-256 от prevHash и содержимого записи, поэтому подмена записи обнаруживается проверкой цепочки
+CREATE TABLE audit_log (
+    id BIGSERIAL PRIMARY KEY,
+    time TIMESTAMPTZ NOT NULL,
+    action TEXT NOT NULL,
+    userId BIGINT NOT NULL DEFAULT 0,
+    actorId BIGINT NOT NULL DEFAULT 0,
+    login TEXT NOT NULL DEFAULT '',
+    ip TEXT NOT NULL DEFAULT '',
+    requestId TEXT NOT NULL DEFAULT '',
+    details JSONB NOT NULL DEFAULT '{}',
+    prevHash TEXT NOT NULL,
+    hash TEXT NOT NULL
+);
+
+CREATE INDEX audit_log_user_idx ON audit_log (userId, time);
+CREATE INDEX audit_log_actor_idx ON audit_log (actorId, time);
+CREATE INDEX audit_log_action_idx ON audit_log (action, time);
+
+CREATE RULE audit_log_no_update AS ON UPDATE TO audit_log DO INSTEAD NOTHING;
+CREATE RULE audit_log_no_delete AS ON DELETE TO audit_log DO INSTEAD NOTHING;
+
+-- голова цепочки: последняя запись журнала, обновляется вместе с добавлением записи,
+-- поэтому удаление записей с конца журнала тоже обнаруживается проверкой цепочки
+CREATE TABLE audit_head (
+    singleton BOOLEAN PRIMARY KEY DEFAULT TRUE CHECK (singleton),
+    lastId BIGINT NOT NULL DEFAULT 0,
+    hash TEXT NOT NULL DEFAULT ''
+);
+
+INSERT INTO audit_head DEFAULT VALUES;
+
+CREATE RULE audit_head_no_delete AS ON DELETE TO audit_head DO INSTEAD NOTHING;
+
+CREATE FUNCTION audit_no_truncate() RETURNS TRIGGER AS $$
+BEGIN
+    RAISE EXCEPTION 'audit log is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER audit_log_no_truncate BEFORE TRUNCATE ON audit_log
+    FOR EACH STATEMENT EXECUTE FUNCTION audit_no_truncate();
+CREATE TRIGGER audit_head_no_truncate BEFORE TRUNCATE ON audit_head
+    FOR EACH STATEMENT EXECUTE FUNCTION audit_no_truncate();
@@ -1,6 +1,6 @@
 module service
 
-go 1.14
+go 1.21
 
 require (
 	github.com/google/uuid v1.1.1
@@ -9,5 +9,19 @@ require (
 	golang.org/x/crypto v0.0.0-20200820211705-5c72a883971a
 )
 
+require (
+	github.com/jackc/chunkreader/v2 v2.0.1 // indirect
+	github.com/jackc/pgconn v1.6.4 // indirect
+	github.com/jackc/pgio v1.0.0 // indirect
+	github.com/jackc/pgpassfile v1.0.0 // indirect
+	github.com/jackc/pgproto3/v2 v2.0.2 // indirect
+	github.com/jackc/pgservicefile v0.0.0-20200714003250-2b9c44734f2b // indirect
+	github.com/jackc/pgtype v1.4.2 // indirect
+	github.com/jackc/puddle v1.1.1 // indirect
+	golang.org/x/sys v0.10.0 // indirect
+	golang.org/x/text v0.3.3 // indirect
+	golang.org/x/xerrors v0.0.0-20191204190536-9bdfabe68543 // indirect
+)
+
 // Инструкция replace позволяет вам не скачивать каждый раз с GitHub/etc, а просто ссылаться на указанный каталог локально
 replace github.com/netology-code/remux => ../remux
@@ -0,0 +1,34 @@
+package audit
+
+import "context"
+
+var actorContextKey = &contextKey{"actor context"}
+
+type contextKey struct {
+	name string
+}
+
+func (c *contextKey) String() string {
+	return c.name
+}
+
+// Actor - кто выполняет запрос: заполняется в http-слое, чтобы записи из security service
+// содержали исполнителя, IP-адрес и идентификатор запроса
+type Actor struct {
+	// UserID - 0, если пользователь не аутентифицирован
+	UserID    int64
+	IP        string
+	RequestID string
+}
+
+func WithActor(ctx context.Context, actor *Actor) context.Context {
+	return context.WithValue(ctx, actorContextKey, actor)
+}
+
+func ActorFrom(ctx context.Context) (*Actor, error) {
+	actor, ok := ctx.Value(actorContextKey).(*Actor)
+	if !ok {
+		return nil, ErrNoActor
+	}
+	return actor, nil
+}
@@ -0,0 +1,346 @@
+package audit
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"log"
+	"strings"
+	"time"
+)
+
+var (
+	ErrNoActor = errors.New("no actor")
+	// ErrChainBroken - запись журнала изменена, удалена или вставлена задним числом
+	ErrChainBroken = errors.New("audit chain broken")
+)
+
+// Действия, записываемые в журнал
+const (
+	ActionLogin              = "login"
+	ActionLoginFailure       = "login.failure"
+	ActionLoginMFARequired   = "login.mfa_required"
+	ActionLoginMFAFailure    = "login.mfa_failure"
+	ActionLogout             = "logout"
+	ActionLogoutAll          = "logout.all"
+	ActionTokenReuse         = "token.reuse"
+	ActionRegister           = "user.register"
+	ActionPasswordChange     = "password.change"
+	ActionRolesChange        = "roles.change"
+	ActionMFAEnable          = "mfa.enable"
+	ActionMFADisable         = "mfa.disable"
+	ActionAPIKeyCreate       = "apikey.create"
+	ActionAPIKeyRevoke       = "apikey.revoke"
+	ActionAuthenticationFail = "authentication.failure"
+	ActionAccessDenied       = "access.denied"
+)
+
+// chainLock - ключ advisory-блокировки, под которой записи добавляются в цепочку по одной
+const chainLock = 0x61756469
+
+// recordTimeout ограничивает запись в журнал: запись не привязана к запросу и не должна висеть вечно
+const recordTimeout = 5 * time.Second
+
+// Entry - запись журнала аудита. Hash - SHA-256 от PrevHash и содержимого записи,
+// поэтому изменение или удаление любой записи обнаруживается Verify.
+type Entry struct {
+	ID     int64     `json:"id"`
+	Time   time.Time `json:"time"`
+	Action string    `json:"action"`
+	// UserID - пользователь, которого касается действие (0 - неизвестен)
+	UserID int64 `json:"userId,omitempty"`
+	// ActorID - пользователь, совершивший действие (0 - аноним), например администратор при смене ролей
+	ActorID   int64             `json:"actorId,omitempty"`
+	Login     string            `json:"login,omitempty"`
+	IP        string            `json:"ip,omitempty"`
+	RequestID string            `json:"requestId,omitempty"`
+	Details   map[string]string `json:"details,omitempty"`
+	PrevHash  string            `json:"prevHash"`
+	Hash      string            `json:"hash"`
+}
+
+// Head - голова цепочки: последняя запись журнала. Хранится отдельно от записей (таблица audit_head),
+// поэтому удаление записей с конца журнала обнаруживается Verify.
+type Head struct {
+	ID   int64  `json:"id"`
+	Hash string `json:"hash"`
+}
+
+// Sink - дополнительное место для записей (например, файл), получает записи после сохранения в БД
+type Sink interface {
+	Write(entry *Entry) error
+}
+
+// Log - журнал аудита: только добавление записей, записи связаны в цепочку хэшами
+type Log struct {
+	pool  *pgxpool.Pool
+	sinks []Sink
+}
+
+type Option func(log *Log)
+
+// WithSink добавляет место, куда дублируются записи
+func WithSink(sink Sink) Option {
+	return func(log *Log) {
+		log.sinks = append(log.sinks, sink)
+	}
+}
+
+func NewLog(pool *pgxpool.Pool, options ...Option) *Log {
+	l := &Log{pool: pool}
+	for _, option := range options {
+		option(l)
+	}
+	return l
+}
+
+// Record добавляет запись в журнал. Пустые ActorID, IP и RequestID берутся из Actor в ctx (см. WithActor).
+// Отмена ctx (например, клиент закрыл соединение) запись не прерывает.
+func (l *Log) Record(ctx context.Context, entry Entry) error {
+	if actor, err := ActorFrom(ctx); err == nil {
+		if entry.ActorID == 0 {
+			entry.ActorID = actor.UserID
+		}
+		if entry.IP == "" {
+			entry.IP = actor.IP
+		}
+		if entry.RequestID == "" {
+			entry.RequestID = actor.RequestID
+		}
+	}
+	if entry.Details == nil {
+		entry.Details = map[string]string{}
+	}
+	// в БД время хранится с точностью до микросекунд, хэш должен совпасть после чтения
+	entry.Time = time.Now().UTC().Truncate(time.Microsecond)
+
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), recordTimeout)
+	defer cancel()
+	tx, err := l.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin: %w", err)
+	}
+	defer func() {
+		_ = tx.Rollback(ctx)
+	}()
+
+	_, err = tx.Exec(ctx, `SELECT pg_advisory_xact_lock($1)`, int64(chainLock))
+	if err != nil {
+		return fmt.Errorf("lock audit chain: %w", err)
+	}
+	err = tx.QueryRow(ctx, `SELECT hash FROM audit_head`).Scan(&entry.PrevHash)
+	if err != nil {
+		return fmt.Errorf("query audit head: %w", err)
+	}
+	err = tx.QueryRow(ctx, `SELECT nextval('audit_log_id_seq')`).Scan(&entry.ID)
+	if err != nil {
+		return fmt.Errorf("next audit id: %w", err)
+	}
+	entry.Hash, err = entry.digest()
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO audit_log (id, time, action, userId, actorId, login, ip, requestId, details, prevHash, hash)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+	`, entry.ID, entry.Time, entry.Action, entry.UserID, entry.ActorID, entry.Login, entry.IP, entry.RequestID,
+		entry.Details, entry.PrevHash, entry.Hash)
+	if err != nil {
+		return fmt.Errorf("insert audit entry: %w", err)
+	}
+	_, err = tx.Exec(ctx, `UPDATE audit_head SET lastId = $1, hash = $2`, entry.ID, entry.Hash)
+	if err != nil {
+		return fmt.Errorf("update audit head: %w", err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("commit: %w", err)
+	}
+
+	// запись в БД уже есть, поэтому ошибки дополнительных мест только пишутся в журнал
+	for _, sink := range l.sinks {
+		if err := sink.Write(&entry); err != nil {
+			log.Printf("can't write audit entry to sink: %v", err)
+		}
+	}
+	return nil
+}
+
+// Filter - условия выборки записей, нулевые значения не ограничивают выборку
+type Filter struct {
+	// UserID - записи, где пользователь - субъект или исполнитель действия
+	UserID int64
+	Action string
+	From   time.Time
+	To     time.Time
+	// Limit - максимальное количество записей (по умолчанию 100), новые записи первыми
+	Limit int
+}
+
+// Query возвращает записи журнала по фильтру
+func (l *Log) Query(ctx context.Context, filter Filter) ([]*Entry, error) {
+	conditions := make([]string, 0)
+	args := make([]interface{}, 0)
+	if filter.UserID != 0 {
+		args = append(args, filter.UserID)
+		conditions = append(conditions, fmt.Sprintf("(userId = $%d OR actorId = $%d)", len(args), len(args)))
+	}
+	if filter.Action != "" {
+		args = append(args, filter.Action)
+		conditions = append(conditions, fmt.Sprintf("action = $%d", len(args)))
+	}
+	if !filter.From.IsZero() {
+		args = append(args, filter.From)
+		conditions = append(conditions, fmt.Sprintf("time >= $%d", len(args)))
+	}
+	if !filter.To.IsZero() {
+		args = append(args, filter.To)
+		conditions = append(conditions, fmt.Sprintf("time < $%d", len(args)))
+	}
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = 100
+	}
+	args = append(args, limit)
+
+	query := `SELECT ` + columns + ` FROM audit_log`
+	if len(conditions) != 0 {
+		query += ` WHERE ` + strings.Join(conditions, " AND ")
+	}
+	query += fmt.Sprintf(` ORDER BY id DESC LIMIT $%d`, len(args))
+
+	rows, err := l.pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query audit log: %w", err)
+	}
+	defer rows.Close()
+
+	entries := make([]*Entry, 0)
+	for rows.Next() {
+		entry, err := scan(rows)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("query audit log: %w", err)
+	}
+	return entries, nil
+}
+
+// ChainError - цепочка нарушена на записи ID
+type ChainError struct {
+	ID int64
+}
+
+func (e *ChainError) Error() string {
+	return fmt.Sprintf("%v at entry %d", ErrChainBroken, e.ID)
+}
+
+func (e *ChainError) Is(target error) bool {
+	return target == ErrChainBroken
+}
+
+// Verify проверяет цепочку хэшей всего журнала и её голову, возвращает количество проверенных записей.
+// Нарушение цепочки - ошибка *ChainError.
+func (l *Log) Verify(ctx context.Context) (int64, error) {
+	rows, err := l.pool.Query(ctx, `SELECT `+columns+` FROM audit_log ORDER BY id`)
+	if err != nil {
+		return 0, fmt.Errorf("query audit log: %w", err)
+	}
+	defer rows.Close()
+
+	chain := &chain{}
+	for rows.Next() {
+		entry, err := scan(rows)
+		if err != nil {
+			return chain.checked, err
+		}
+		if err := chain.add(entry); err != nil {
+			return chain.checked, err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return chain.checked, fmt.Errorf("query audit log: %w", err)
+	}
+	rows.Close()
+
+	head, err := l.Head(ctx)
+	if err != nil {
+		return chain.checked, err
+	}
+	return chain.checked, chain.end(head)
+}
+
+// Head возвращает голову цепочки. Её стоит сохранять вне БД (см. Sink), чтобы обнаружить откат всего журнала.
+func (l *Log) Head(ctx context.Context) (*Head, error) {
+	head := &Head{}
+	err := l.pool.QueryRow(ctx, `SELECT lastId, hash FROM audit_head`).Scan(&head.ID, &head.Hash)
+	if err != nil {
+		return nil, fmt.Errorf("query audit head: %w", err)
+	}
+	return head, nil
+}
+
+// chain - состояние проверки цепочки: записи передаются в add по порядку id
+type chain struct {
+	checked  int64
+	lastID   int64
+	prevHash string
+}
+
+func (c *chain) add(entry *Entry) error {
+	hash, err := entry.digest()
+	if err != nil {
+		return err
+	}
+	if entry.PrevHash != c.prevHash || entry.Hash != hash {
+		return &ChainError{ID: entry.ID}
+	}
+	c.lastID = entry.ID
+	c.prevHash = entry.Hash
+	c.checked++
+	return nil
+}
+
+// end сверяет последнюю запись с головой: расхождение значит, что записи удалены с конца журнала
+func (c *chain) end(head *Head) error {
+	if head.ID != c.lastID || head.Hash != c.prevHash {
+		return &ChainError{ID: max(head.ID, c.lastID)}
+	}
+	return nil
+}
+
+const columns = `id, time, action, userId, actorId, login, ip, requestId, details, prevHash, hash`
+
+func scan(rows pgx.Rows) (*Entry, error) {
+	entry := &Entry{}
+	err := rows.Scan(&entry.ID, &entry.Time, &entry.Action, &entry.UserID, &entry.ActorID, &entry.Login, &entry.IP,
+		&entry.RequestID, &entry.Details, &entry.PrevHash, &entry.Hash)
+	if err != nil {
+		return nil, fmt.Errorf("scan audit entry: %w", err)
+	}
+	entry.Time = entry.Time.UTC()
+	if entry.Details == nil {
+		entry.Details = map[string]string{}
+	}
+	return entry, nil
+}
+
+// digest считает хэш записи: все поля, кроме Hash, в фиксированном порядке (ключи Details json сортирует сам)
+func (e *Entry) digest() (string, error) {
+	content, err := json.Marshal([]interface{}{
+		e.ID, e.Time.UTC().Format(time.RFC3339Nano), e.Action, e.UserID, e.ActorID, e.Login, e.IP, e.RequestID, e.Details,
+	})
+	if err != nil {
+		return "", fmt.Errorf("marshal audit entry: %w", err)
+	}
+	sum := sha256.Sum256(append([]byte(e.PrevHash+"\n"), content...))
+	return hex.EncodeToString(sum[:]), nil
+}
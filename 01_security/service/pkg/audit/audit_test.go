@@ -0,0 +1,127 @@
+package audit
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// entries возвращает цепочку из count записей с корректными хэшами
+func entries(t *testing.T, count int) []*Entry {
+	result := make([]*Entry, 0, count)
+	prevHash := ""
+	for i := 1; i <= count; i++ {
+		entry := &Entry{
+			ID:       int64(i),
+			Time:     time.Date(2020, 1, 1, 0, 0, i, 0, time.UTC),
+			Action:   ActionLogin,
+			UserID:   1,
+			Login:    "admin",
+			Details:  map[string]string{"b": "2", "a": "1"},
+			PrevHash: prevHash,
+		}
+		hash, err := entry.digest()
+		if err != nil {
+			t.Fatal(err)
+		}
+		entry.Hash = hash
+		prevHash = hash
+		result = append(result, entry)
+	}
+	return result
+}
+
+func TestEntry_digest(t *testing.T) {
+	entry := entries(t, 1)[0]
+
+	tests := []struct {
+		name   string
+		change func(entry *Entry)
+		same   bool
+	}{
+		{name: "unchanged", change: func(entry *Entry) {}, same: true},
+		{name: "hash ignored", change: func(entry *Entry) { entry.Hash = "" }, same: true},
+		{name: "time zone ignored", change: func(entry *Entry) { entry.Time = entry.Time.In(time.FixedZone("MSK", 3*60*60)) }, same: true},
+		{name: "details order ignored", change: func(entry *Entry) { entry.Details = map[string]string{"a": "1", "b": "2"} }, same: true},
+		{name: "id", change: func(entry *Entry) { entry.ID = 2 }},
+		{name: "time", change: func(entry *Entry) { entry.Time = entry.Time.Add(time.Microsecond) }},
+		{name: "action", change: func(entry *Entry) { entry.Action = ActionLogout }},
+		{name: "user", change: func(entry *Entry) { entry.UserID = 2 }},
+		{name: "actor", change: func(entry *Entry) { entry.ActorID = 1 }},
+		{name: "login", change: func(entry *Entry) { entry.Login = "user" }},
+		{name: "ip", change: func(entry *Entry) { entry.IP = "192.0.2.1" }},
+		{name: "request id", change: func(entry *Entry) { entry.RequestID = "id" }},
+		{name: "details", change: func(entry *Entry) { entry.Details = map[string]string{"a": "1"} }},
+		{name: "prev hash", change: func(entry *Entry) { entry.PrevHash = "0" }},
+	}
+
+	for _, tt := range tests {
+		changed := *entry
+		tt.change(&changed)
+		got, err := changed.digest()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if same := got == entry.Hash; same != tt.same {
+			t.Errorf("%s: got same %v, want %v", tt.name, same, tt.same)
+		}
+	}
+}
+
+func TestChain(t *testing.T) {
+	type args struct {
+		entries []*Entry
+		head    *Head
+	}
+
+	full := entries(t, 3)
+	modified := entries(t, 3)
+	modified[1].Login = "user"
+	replaced := entries(t, 3)
+	replaced[1] = entries(t, 2)[1]
+	replaced[1].Action = ActionLogout
+	replaced[1].Hash, _ = replaced[1].digest()
+
+	tests := []struct {
+		name    string
+		args    args
+		checked int64
+		broken  int64
+	}{
+		{name: "empty", args: args{entries: nil, head: &Head{}}, checked: 0},
+		{name: "valid", args: args{entries: full, head: &Head{ID: 3, Hash: full[2].Hash}}, checked: 3},
+		{name: "modified", args: args{entries: modified, head: &Head{ID: 3, Hash: modified[2].Hash}}, checked: 1, broken: 2},
+		{name: "rehashed", args: args{entries: replaced, head: &Head{ID: 3, Hash: replaced[2].Hash}}, checked: 2, broken: 3},
+		{name: "deleted", args: args{entries: []*Entry{full[0], full[2]}, head: &Head{ID: 3, Hash: full[2].Hash}}, checked: 1, broken: 3},
+		{name: "tail deleted", args: args{entries: full[:2], head: &Head{ID: 3, Hash: full[2].Hash}}, checked: 2, broken: 3},
+		{name: "all deleted", args: args{entries: nil, head: &Head{ID: 3, Hash: full[2].Hash}}, checked: 0, broken: 3},
+		{name: "head reset", args: args{entries: full, head: &Head{}}, checked: 3, broken: 3},
+	}
+
+	for _, tt := range tests {
+		chain := &chain{}
+		var err error
+		for _, entry := range tt.args.entries {
+			if err = chain.add(entry); err != nil {
+				break
+			}
+		}
+		if err == nil {
+			err = chain.end(tt.args.head)
+		}
+
+		if chain.checked != tt.checked {
+			t.Errorf("%s: got checked %v, want %v", tt.name, chain.checked, tt.checked)
+		}
+		var broken *ChainError
+		if tt.broken == 0 {
+			if err != nil {
+				t.Errorf("%s: got error %v, want nil", tt.name, err)
+			}
+			continue
+		}
+		if !errors.As(err, &broken) || broken.ID != tt.broken || !errors.Is(err, ErrChainBroken) {
+			t.Errorf("%s: got error %v, want broken at %v", tt.name, err, tt.broken)
+		}
+	}
+}
@@ -0,0 +1,31 @@
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// JSONLines пишет записи в writer по одной JSON-строке (например, в файл, который забирает сборщик журналов)
+type JSONLines struct {
+	mu     sync.Mutex
+	writer io.Writer
+}
+
+func NewJSONLines(writer io.Writer) *JSONLines {
+	return &JSONLines{writer: writer}
+}
+
+func (j *JSONLines) Write(entry *Entry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("marshal audit entry: %w", err)
+	}
+	line = append(line, '\n')
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	_, err = j.writer.Write(line)
+	return err
+}
@@ -0,0 +1,202 @@
+package security
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/jackc/pgx/v4"
+	"log"
+	"service/pkg/audit"
+	"strings"
+	"time"
+)
+
+var (
+	ErrAPIKeyNotFound  = errors.New("api key not found")
+	ErrInvalidKeyName  = errors.New("invalid api key name")
+	ErrInvalidKeyScope = errors.New("api key scope exceeds owner roles")
+)
+
+// Ключ имеет вид sk_<prefix>_<secret>: префикс хранится открыто (по нему ключ ищется и показывается в списке),
+// от ключа целиком хранится только SHA-256
+const (
+	apiKeyMarker     = "sk_"
+	apiKeyPrefixSize = 5
+	apiKeySecretSize = 20
+)
+
+// APIKey - персональный ключ для скриптов, действует от имени владельца с ролями Roles
+type APIKey struct {
+	ID   int64
+	Name string
+	// Prefix - начало ключа (sk_xxxxxxxx), чтобы его можно было узнать в списке
+	Prefix string
+	// Roles - роли, которыми ограничен ключ (подмножество ролей владельца)
+	Roles    []string
+	Created  time.Time
+	LastUsed *time.Time
+}
+
+// CreateAPIKey выпускает ключ name с ролями roles (nil - все текущие роли владельца).
+// Ключ целиком возвращается только здесь, потом его не восстановить.
+func (s *Service) CreateAPIKey(ctx context.Context, userID int64, name string, roles []string) (string, *APIKey, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", nil, ErrInvalidKeyName
+	}
+
+	var ownerRoles []string
+	err := s.pool.QueryRow(ctx, `SELECT roles FROM users WHERE id = $1`, userID).Scan(&ownerRoles)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "", nil, ErrUserNotFound
+		}
+		return "", nil, fmt.Errorf("query user: %w", err)
+	}
+	if roles == nil {
+		roles = ownerRoles
+	}
+	for _, role := range roles {
+		if !contains(ownerRoles, role) {
+			return "", nil, fmt.Errorf("%w: %s", ErrInvalidKeyScope, role)
+		}
+	}
+
+	prefix, err := randomToken(apiKeyPrefixSize)
+	if err != nil {
+		return "", nil, fmt.Errorf("generate api key: %w", err)
+	}
+	secret, err := randomToken(apiKeySecretSize)
+	if err != nil {
+		return "", nil, fmt.Errorf("generate api key: %w", err)
+	}
+	key := apiKeyMarker + prefix + "_" + secret
+
+	apiKey := &APIKey{Name: name, Prefix: apiKeyMarker + prefix, Roles: roles}
+	err = s.pool.QueryRow(ctx, `
+		INSERT INTO api_keys (userId, name, prefix, hash, roles) VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created
+	`, userID, name, apiKey.Prefix, hashAPIKey(key), roles).Scan(&apiKey.ID, &apiKey.Created)
+	if err != nil {
+		return "", nil, fmt.Errorf("insert api key: %w", err)
+	}
+	s.record(ctx, audit.Entry{
+		Action:  audit.ActionAPIKeyCreate,
+		UserID:  userID,
+		Details: map[string]string{"prefix": apiKey.Prefix, "name": name, "roles": strings.Join(roles, ",")},
+	})
+	return key, apiKey, nil
+}
+
+// APIKeys возвращает ключи пользователя (без самих ключей)
+func (s *Service) APIKeys(ctx context.Context, userID int64) ([]*APIKey, error) {
+	rows, err := s.pool.Query(ctx, `
+		SELECT id, name, prefix, roles, created, last_used FROM api_keys WHERE userId = $1 ORDER BY id
+	`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("query api keys: %w", err)
+	}
+	defer rows.Close()
+
+	keys := make([]*APIKey, 0)
+	for rows.Next() {
+		key := &APIKey{}
+		err = rows.Scan(&key.ID, &key.Name, &key.Prefix, &key.Roles, &key.Created, &key.LastUsed)
+		if err != nil {
+			return nil, fmt.Errorf("scan api key: %w", err)
+		}
+		keys = append(keys, key)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("query api keys: %w", err)
+	}
+	return keys, nil
+}
+
+// RevokeAPIKey удаляет ключ keyID, принадлежащий пользователю userID
+func (s *Service) RevokeAPIKey(ctx context.Context, userID int64, keyID int64) error {
+	tag, err := s.pool.Exec(ctx, `DELETE FROM api_keys WHERE id = $1 AND userId = $2`, keyID, userID)
+	if err != nil {
+		return fmt.Errorf("delete api key: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrAPIKeyNotFound
+	}
+	s.record(ctx, audit.Entry{Action: audit.ActionAPIKeyRevoke, UserID: userID, Details: map[string]string{"id": fmt.Sprint(keyID)}})
+	return nil
+}
+
+// apiKeyDetails возвращает профиль владельца ключа, роли ключа попадают в UserDetails.Scopes
+func (s *Service) apiKeyDetails(ctx context.Context, key string) (*UserDetails, error) {
+	prefix, ok := apiKeyPrefix(key)
+	if !ok {
+		return nil, ErrUserNotFound
+	}
+
+	details := &UserDetails{}
+	var hash string
+	err := s.pool.QueryRow(ctx, `
+		SELECT u.id, u.login, u.roles, k.id, k.roles, k.hash FROM api_keys k JOIN users u ON k.userId = u.id WHERE k.prefix = $1
+	`, prefix).Scan(&details.ID, &details.Login, &details.Roles, &details.APIKeyID, &details.Scopes, &hash)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrUserNotFound
+		}
+		return nil, fmt.Errorf("query api key: %w", err)
+	}
+	if subtle.ConstantTimeCompare([]byte(hashAPIKey(key)), []byte(hash)) != 1 {
+		return nil, ErrUserNotFound
+	}
+
+	// профили кэшируются, поэтому время использования обновляется не при каждом запросе
+	_, err = s.pool.Exec(ctx, `UPDATE api_keys SET last_used = CURRENT_TIMESTAMP WHERE id = $1`, details.APIKeyID)
+	if err != nil {
+		log.Printf("can't update api key usage: %v", err)
+	}
+	return details, nil
+}
+
+// isAPIKey отличает ключ от токена сессии
+func isAPIKey(token string) bool {
+	return strings.HasPrefix(token, apiKeyMarker)
+}
+
+// apiKeyPrefix возвращает открытую часть ключа sk_<prefix>_<secret>
+func apiKeyPrefix(key string) (string, bool) {
+	if !isAPIKey(key) {
+		return "", false
+	}
+	end := strings.IndexByte(key[len(apiKeyMarker):], '_')
+	if end <= 0 {
+		return "", false
+	}
+	return key[:len(apiKeyMarker)+end], true
+}
+
+// hashAPIKey: у ключа достаточно энтропии, поэтому медленный хэш (как для паролей) не нужен
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// randomToken возвращает size случайных байт в base32 нижнем регистре
+func randomToken(size int) (string, error) {
+	random := make([]byte, size)
+	if _, err := rand.Read(random); err != nil {
+		return "", err
+	}
+	return strings.ToLower(totpEncoding.EncodeToString(random)), nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
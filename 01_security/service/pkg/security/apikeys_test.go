@@ -0,0 +1,49 @@
+package security
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAPIKeyPrefix(t *testing.T) {
+	tests := []struct {
+		name   string
+		key    string
+		prefix string
+		ok     bool
+	}{
+		{name: "key", key: "sk_abcdefgh_secret", prefix: "sk_abcdefgh", ok: true},
+		{name: "secret with underscore", key: "sk_abcdefgh_sec_ret", prefix: "sk_abcdefgh", ok: true},
+		{name: "no secret", key: "sk_abcdefgh", ok: false},
+		{name: "empty prefix", key: "sk__secret", ok: false},
+		{name: "session token", key: "3f2b8c1e-0d5a-4c7e-9b1f-6a2d8e4c0b7a", ok: false},
+		{name: "empty", key: "", ok: false},
+	}
+
+	for _, tt := range tests {
+		prefix, ok := apiKeyPrefix(tt.key)
+		if prefix != tt.prefix || ok != tt.ok {
+			t.Errorf("%s: got %q %v, want %q %v", tt.name, prefix, ok, tt.prefix, tt.ok)
+		}
+	}
+}
+
+func TestRandomToken(t *testing.T) {
+	first, err := randomToken(apiKeySecretSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := randomToken(apiKeySecretSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first == second {
+		t.Errorf("got equal tokens %v", first)
+	}
+	if first != strings.ToLower(first) || strings.Contains(first, "_") {
+		t.Errorf("got %v, want lower case base32 without underscores", first)
+	}
+	if hashAPIKey("sk_a_b") == hashAPIKey("sk_a_c") {
+		t.Error("got equal hashes for different keys")
+	}
+}
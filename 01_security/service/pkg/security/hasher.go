@@ -0,0 +1,281 @@
+package security
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/crypto/scrypt"
+	"strings"
+)
+
+// ErrUnknownHash - хэш не распознан ни одним из алгоритмов или повреждён
+var ErrUnknownHash = errors.New("unknown password hash format")
+
+// PasswordHasher - алгоритм хэширования паролей. Алгоритм узнаёт свои хэши по префиксу строки PHC
+// ($2a$ для bcrypt, $argon2id$, $scrypt$), параметры хранятся в самом хэше.
+type PasswordHasher interface {
+	// Hash возвращает хэш пароля со случайной солью
+	Hash(password []byte) (string, error)
+	// Verify сравнивает пароль с хэшем: ErrInvalidPassword - не совпадает, ErrUnknownHash - хэш повреждён
+	Verify(hash string, password []byte) error
+	// Identifies проверяет, посчитан ли хэш этим алгоритмом
+	Identifies(hash string) bool
+	// NeedsRehash - хэш этого алгоритма посчитан с параметрами слабее текущих
+	NeedsRehash(hash string) bool
+}
+
+const (
+	hashSaltSize = 16
+	hashKeySize  = 32
+)
+
+// BcryptHasher - bcrypt, Cost = 0 - bcrypt.DefaultCost
+type BcryptHasher struct {
+	Cost int
+}
+
+func (h *BcryptHasher) Hash(password []byte) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword(password, h.cost())
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+func (h *BcryptHasher) Verify(hash string, password []byte) error {
+	err := bcrypt.CompareHashAndPassword([]byte(hash), password)
+	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		return ErrInvalidPassword
+	}
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrUnknownHash, err)
+	}
+	return nil
+}
+
+func (h *BcryptHasher) Identifies(hash string) bool {
+	return strings.HasPrefix(hash, "$2a$") || strings.HasPrefix(hash, "$2b$") || strings.HasPrefix(hash, "$2y$")
+}
+
+func (h *BcryptHasher) NeedsRehash(hash string) bool {
+	cost, err := bcrypt.Cost([]byte(hash))
+	return err == nil && cost < h.cost()
+}
+
+func (h *BcryptHasher) cost() int {
+	if h.Cost == 0 {
+		return bcrypt.DefaultCost
+	}
+	return h.Cost
+}
+
+// Argon2idHasher - argon2id (RFC 9106), хэш вида $argon2id$v=19$m=65536,t=3,p=2$<соль>$<хэш>.
+// Нулевые параметры заменяются значениями по умолчанию: Time 3, Memory 64 MiB, Threads 2.
+type Argon2idHasher struct {
+	Time uint32
+	// Memory - в KiB
+	Memory  uint32
+	Threads uint8
+}
+
+func (h *Argon2idHasher) Hash(password []byte) (string, error) {
+	p := h.params()
+	salt, err := newSalt()
+	if err != nil {
+		return "", err
+	}
+	key := argon2.IDKey(password, salt, p.Time, p.Memory, p.Threads, hashKeySize)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", argon2.Version, p.Memory, p.Time, p.Threads,
+		encodeHash(salt), encodeHash(key)), nil
+}
+
+func (h *Argon2idHasher) Verify(hash string, password []byte) error {
+	p, salt, key, err := parseArgon2id(hash)
+	if err != nil {
+		return err
+	}
+	return compareKeys(argon2.IDKey(password, salt, p.Time, p.Memory, p.Threads, uint32(len(key))), key)
+}
+
+func (h *Argon2idHasher) Identifies(hash string) bool {
+	return strings.HasPrefix(hash, "$argon2id$")
+}
+
+func (h *Argon2idHasher) NeedsRehash(hash string) bool {
+	p, _, _, err := parseArgon2id(hash)
+	current := h.params()
+	return err == nil && (p.Time < current.Time || p.Memory < current.Memory || p.Threads < current.Threads)
+}
+
+func (h *Argon2idHasher) params() Argon2idHasher {
+	p := *h
+	if p.Time == 0 {
+		p.Time = 3
+	}
+	if p.Memory == 0 {
+		p.Memory = 64 * 1024
+	}
+	if p.Threads == 0 {
+		p.Threads = 2
+	}
+	return p
+}
+
+func parseArgon2id(hash string) (*Argon2idHasher, []byte, []byte, error) {
+	p := &Argon2idHasher{}
+	salt, key, err := parsePHC(hash, "argon2id", func(fields []string) error {
+		if len(fields) != 2 {
+			return ErrUnknownHash
+		}
+		var version int
+		if _, err := fmt.Sscanf(fields[0], "v=%d", &version); err != nil || version != argon2.Version {
+			return ErrUnknownHash
+		}
+		_, err := fmt.Sscanf(fields[1], "m=%d,t=%d,p=%d", &p.Memory, &p.Time, &p.Threads)
+		return err
+	})
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if p.Time == 0 || p.Threads == 0 {
+		return nil, nil, nil, ErrUnknownHash
+	}
+	return p, salt, key, nil
+}
+
+// ScryptHasher - scrypt, хэш вида $scrypt$ln=15,r=8,p=1$<соль>$<хэш> (ln - log2(N)).
+// Нулевые параметры заменяются значениями по умолчанию: LogN 15, R 8, P 1.
+type ScryptHasher struct {
+	LogN int
+	R    int
+	P    int
+}
+
+func (h *ScryptHasher) Hash(password []byte) (string, error) {
+	p := h.params()
+	salt, err := newSalt()
+	if err != nil {
+		return "", err
+	}
+	key, err := scrypt.Key(password, salt, 1<<p.LogN, p.R, p.P, hashKeySize)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("$scrypt$ln=%d,r=%d,p=%d$%s$%s", p.LogN, p.R, p.P, encodeHash(salt), encodeHash(key)), nil
+}
+
+func (h *ScryptHasher) Verify(hash string, password []byte) error {
+	p, salt, key, err := parseScrypt(hash)
+	if err != nil {
+		return err
+	}
+	derived, err := scrypt.Key(password, salt, 1<<p.LogN, p.R, p.P, len(key))
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrUnknownHash, err)
+	}
+	return compareKeys(derived, key)
+}
+
+func (h *ScryptHasher) Identifies(hash string) bool {
+	return strings.HasPrefix(hash, "$scrypt$")
+}
+
+func (h *ScryptHasher) NeedsRehash(hash string) bool {
+	p, _, _, err := parseScrypt(hash)
+	current := h.params()
+	return err == nil && (p.LogN < current.LogN || p.R < current.R || p.P < current.P)
+}
+
+func (h *ScryptHasher) params() ScryptHasher {
+	p := *h
+	if p.LogN == 0 {
+		p.LogN = 15
+	}
+	if p.R == 0 {
+		p.R = 8
+	}
+	if p.P == 0 {
+		p.P = 1
+	}
+	return p
+}
+
+func parseScrypt(hash string) (*ScryptHasher, []byte, []byte, error) {
+	p := &ScryptHasher{}
+	salt, key, err := parsePHC(hash, "scrypt", func(fields []string) error {
+		if len(fields) != 1 {
+			return ErrUnknownHash
+		}
+		_, err := fmt.Sscanf(fields[0], "ln=%d,r=%d,p=%d", &p.LogN, &p.R, &p.P)
+		return err
+	})
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if p.LogN <= 0 || p.LogN >= 32 {
+		return nil, nil, nil, ErrUnknownHash
+	}
+	return p, salt, key, nil
+}
+
+// Hashers - все поддерживаемые алгоритмы: хэш любого из них можно проверить
+var Hashers = []PasswordHasher{&BcryptHasher{}, &Argon2idHasher{}, &ScryptHasher{}}
+
+// VerifyPassword проверяет пароль хэшем любого поддерживаемого алгоритма
+func VerifyPassword(hash string, password []byte) error {
+	for _, hasher := range Hashers {
+		if hasher.Identifies(hash) {
+			return hasher.Verify(hash, password)
+		}
+	}
+	return ErrUnknownHash
+}
+
+// NeedsUpgrade - хэш нужно пересчитать предпочтительным алгоритмом preferred
+// (посчитан другим алгоритмом или с более слабыми параметрами)
+func NeedsUpgrade(preferred PasswordHasher, hash string) bool {
+	return !preferred.Identifies(hash) || preferred.NeedsRehash(hash)
+}
+
+// parsePHC разбирает строку $<id>$<параметры...>$<соль>$<хэш>, параметры разбирает parse
+func parsePHC(hash string, id string, parse func(fields []string) error) ([]byte, []byte, error) {
+	fields := strings.Split(hash, "$")
+	if len(fields) < 4 || fields[0] != "" || fields[1] != id {
+		return nil, nil, ErrUnknownHash
+	}
+	if err := parse(fields[2 : len(fields)-2]); err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", ErrUnknownHash, err)
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(fields[len(fields)-2])
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", ErrUnknownHash, err)
+	}
+	key, err := base64.RawStdEncoding.DecodeString(fields[len(fields)-1])
+	if err != nil || len(key) == 0 {
+		return nil, nil, ErrUnknownHash
+	}
+	return salt, key, nil
+}
+
+func compareKeys(derived []byte, key []byte) error {
+	if subtle.ConstantTimeCompare(derived, key) != 1 {
+		return ErrInvalidPassword
+	}
+	return nil
+}
+
+func newSalt() ([]byte, error) {
+	salt := make([]byte, hashSaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	return salt, nil
+}
+
+func encodeHash(data []byte) string {
+	return base64.RawStdEncoding.EncodeToString(data)
+}
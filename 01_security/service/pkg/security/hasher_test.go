@@ -0,0 +1,164 @@
+package security
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// слабые параметры, чтобы тесты шли быстро
+var testHashers = []struct {
+	name   string
+	hasher PasswordHasher
+}{
+	{name: "bcrypt", hasher: &BcryptHasher{Cost: 4}},
+	{name: "argon2id", hasher: &Argon2idHasher{Time: 1, Memory: 1024, Threads: 1}},
+	{name: "scrypt", hasher: &ScryptHasher{LogN: 10}},
+}
+
+// hasher.go скопирован в auth-сервисы 09, 10 и 11, после изменений копии обновляет sync-hashers.sh
+func TestHasherCopies(t *testing.T) {
+	script := filepath.Join("..", "..", "sync-hashers.sh")
+	if _, err := os.Stat(script); err != nil {
+		t.Skip("sync-hashers.sh is not available")
+	}
+	if output, err := exec.Command("sh", script, "-check").CombinedOutput(); err != nil {
+		t.Errorf("%v: %s", err, output)
+	}
+}
+
+func TestPasswordHasher_Verify(t *testing.T) {
+	for _, tt := range testHashers {
+		hash, err := tt.hasher.Hash([]byte("secret"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !tt.hasher.Identifies(hash) {
+			t.Errorf("%s: hash %v not identified", tt.name, hash)
+		}
+		for _, other := range testHashers {
+			if other.name != tt.name && other.hasher.Identifies(hash) {
+				t.Errorf("%s: hash %v identified by %s", tt.name, hash, other.name)
+			}
+		}
+
+		if err := tt.hasher.Verify(hash, []byte("secret")); err != nil {
+			t.Errorf("%s: got %v, want nil", tt.name, err)
+		}
+		if err := VerifyPassword(hash, []byte("secret")); err != nil {
+			t.Errorf("%s: VerifyPassword got %v, want nil", tt.name, err)
+		}
+		if err := VerifyPassword(hash, []byte("Secret")); !errors.Is(err, ErrInvalidPassword) {
+			t.Errorf("%s: got %v, want %v", tt.name, err, ErrInvalidPassword)
+		}
+
+		another, err := tt.hasher.Hash([]byte("secret"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if another == hash {
+			t.Errorf("%s: got equal hashes, want random salt", tt.name)
+		}
+	}
+}
+
+func TestVerifyPassword_Malformed(t *testing.T) {
+	tests := []struct {
+		name string
+		hash string
+	}{
+		{name: "empty", hash: ""},
+		{name: "plain text", hash: "secret"},
+		{name: "unknown algorithm", hash: "$pbkdf2-sha256$i=1000$c2FsdA$a2V5"},
+		{name: "bcrypt truncated", hash: "$2a$04$abc"},
+		{name: "argon2id no key", hash: "$argon2id$v=19$m=1024,t=1,p=1$c2FsdA"},
+		{name: "argon2id empty key", hash: "$argon2id$v=19$m=1024,t=1,p=1$c2FsdA$"},
+		{name: "argon2id bad version", hash: "$argon2id$v=16$m=1024,t=1,p=1$c2FsdA$a2V5"},
+		{name: "argon2id bad params", hash: "$argon2id$v=19$m=1024$c2FsdA$a2V5"},
+		{name: "argon2id zero time", hash: "$argon2id$v=19$m=1024,t=0,p=1$c2FsdA$a2V5"},
+		{name: "argon2id bad salt", hash: "$argon2id$v=19$m=1024,t=1,p=1$!!$a2V5"},
+		{name: "scrypt bad key", hash: "$scrypt$ln=10,r=8,p=1$c2FsdA$!!"},
+		{name: "scrypt too large", hash: "$scrypt$ln=40,r=8,p=1$c2FsdA$a2V5"},
+		{name: "scrypt extra field", hash: "$scrypt$ln=10,r=8,p=1$x$c2FsdA$a2V5"},
+	}
+
+	for _, tt := range tests {
+		if err := VerifyPassword(tt.hash, []byte("secret")); !errors.Is(err, ErrUnknownHash) {
+			t.Errorf("%s: got %v, want %v", tt.name, err, ErrUnknownHash)
+		}
+	}
+}
+
+func TestPasswordHasher_NeedsRehash(t *testing.T) {
+	type args struct {
+		hashed  PasswordHasher
+		current PasswordHasher
+	}
+
+	tests := []struct {
+		name string
+		args args
+		want bool
+	}{
+		{name: "bcrypt same", args: args{hashed: &BcryptHasher{Cost: 4}, current: &BcryptHasher{Cost: 4}}, want: false},
+		{name: "bcrypt weaker", args: args{hashed: &BcryptHasher{Cost: 4}, current: &BcryptHasher{Cost: 5}}, want: true},
+		{name: "bcrypt stronger", args: args{hashed: &BcryptHasher{Cost: 5}, current: &BcryptHasher{Cost: 4}}, want: false},
+		{name: "argon2id same", args: args{hashed: &Argon2idHasher{Time: 1, Memory: 1024, Threads: 1}, current: &Argon2idHasher{Time: 1, Memory: 1024, Threads: 1}}, want: false},
+		{name: "argon2id less time", args: args{hashed: &Argon2idHasher{Time: 1, Memory: 1024, Threads: 1}, current: &Argon2idHasher{Time: 2, Memory: 1024, Threads: 1}}, want: true},
+		{name: "argon2id less memory", args: args{hashed: &Argon2idHasher{Time: 1, Memory: 1024, Threads: 1}, current: &Argon2idHasher{Time: 1, Memory: 2048, Threads: 1}}, want: true},
+		{name: "scrypt same", args: args{hashed: &ScryptHasher{LogN: 10}, current: &ScryptHasher{LogN: 10}}, want: false},
+		{name: "scrypt weaker", args: args{hashed: &ScryptHasher{LogN: 10}, current: &ScryptHasher{LogN: 11}}, want: true},
+	}
+
+	for _, tt := range tests {
+		hash, err := tt.args.hashed.Hash([]byte("secret"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := tt.args.current.NeedsRehash(hash); got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestArgon2idHasher_Format(t *testing.T) {
+	hash, err := (&Argon2idHasher{Time: 1, Memory: 1024, Threads: 1}).Hash([]byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(hash, "$argon2id$v=19$m=1024,t=1,p=1$") {
+		t.Errorf("got %v", hash)
+	}
+}
+
+func TestNeedsUpgrade(t *testing.T) {
+	preferred := &Argon2idHasher{Time: 1, Memory: 1024, Threads: 1}
+
+	type args struct {
+		hashed PasswordHasher
+	}
+
+	tests := []struct {
+		name string
+		args args
+		want bool
+	}{
+		{name: "preferred", args: args{hashed: preferred}, want: false},
+		{name: "weaker parameters", args: args{hashed: &Argon2idHasher{Time: 1, Memory: 512, Threads: 1}}, want: true},
+		{name: "bcrypt", args: args{hashed: &BcryptHasher{Cost: 4}}, want: true},
+		{name: "scrypt", args: args{hashed: &ScryptHasher{LogN: 10}}, want: true},
+	}
+
+	for _, tt := range tests {
+		hash, err := tt.args.hashed.Hash([]byte("secret"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := NeedsUpgrade(preferred, hash); got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
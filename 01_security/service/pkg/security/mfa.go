@@ -0,0 +1,345 @@
+package security
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v4"
+	"service/pkg/audit"
+	"strings"
+	"time"
+)
+
+var (
+	ErrMFAAlreadyEnabled = errors.New("two-factor authentication already enabled")
+	ErrMFANotEnrolled    = errors.New("two-factor authentication not enrolled")
+	ErrMFARequired       = errors.New("two-factor authentication required")
+	ErrInvalidMFAToken   = errors.New("invalid or expired mfa token")
+	ErrInvalidMFACode    = errors.New("invalid mfa code")
+)
+
+const (
+	recoveryCodesCount = 10
+	// maxMFAAttempts - сколько неверных кодов можно ввести по одному mfa-токену
+	// (общее ограничение по пользователю - в ThrottleConfig, ключ mfa:<id>)
+	maxMFAAttempts = 5
+	// NoSkew - принимать код только текущего интервала
+	NoSkew = -1
+)
+
+// MFAConfig - параметры двухфакторной аутентификации
+type MFAConfig struct {
+	// Issuer - название сервиса в приложении-аутентификаторе
+	Issuer string
+	// Skew - сколько соседних 30-секундных интервалов принимать из-за расхождения часов,
+	// по умолчанию 1 (RFC 6238, раздел 5.2); NoSkew - только текущий интервал
+	Skew int
+	// PendingTTL - время жизни mfa-токена между вводом пароля и вводом кода
+	PendingTTL time.Duration
+}
+
+// WithMFA задаёт параметры двухфакторной аутентификации
+func WithMFA(config MFAConfig) Option {
+	return func(service *Service) {
+		service.mfa = config.withDefaults()
+	}
+}
+
+func (c MFAConfig) withDefaults() MFAConfig {
+	if c.Issuer == "" {
+		c.Issuer = "service"
+	}
+	if c.Skew == 0 {
+		c.Skew = 1
+	}
+	if c.Skew < 0 {
+		c.Skew = 0
+	}
+	if c.PendingTTL <= 0 {
+		c.PendingTTL = 5 * time.Minute
+	}
+	return c
+}
+
+// TOTPEnrollment - данные для настройки приложения-аутентификатора
+type TOTPEnrollment struct {
+	Secret string
+	// URI - otpauth:// URI для QR-кода
+	URI string
+}
+
+// EnrollTOTP генерирует новый секрет TOTP. Двухфакторная аутентификация включится после ConfirmTOTP.
+func (s *Service) EnrollTOTP(ctx context.Context, userID int64) (*TOTPEnrollment, error) {
+	var login string
+	var enabled bool
+	err := s.pool.QueryRow(ctx, `
+		SELECT login, totp_enabled FROM users WHERE id = $1
+	`, userID).Scan(&login, &enabled)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrUserNotFound
+		}
+		return nil, fmt.Errorf("query user: %w", err)
+	}
+	if enabled {
+		return nil, ErrMFAAlreadyEnabled
+	}
+
+	secret, err := newTOTPSecret()
+	if err != nil {
+		return nil, fmt.Errorf("generate secret: %w", err)
+	}
+	_, err = s.pool.Exec(ctx, `UPDATE users SET totp_secret = $2, totp_last_step = 0 WHERE id = $1`, userID, secret)
+	if err != nil {
+		return nil, fmt.Errorf("update totp secret: %w", err)
+	}
+
+	return &TOTPEnrollment{Secret: secret, URI: totpURI(s.mfa.Issuer, login, secret)}, nil
+}
+
+// ConfirmTOTP включает двухфакторную аутентификацию, если code подходит к секрету из EnrollTOTP,
+// и возвращает одноразовые коды восстановления (в базе хранятся только их хэши)
+func (s *Service) ConfirmTOTP(ctx context.Context, userID int64, code string) ([]string, error) {
+	var codes []string
+	err := s.inTx(ctx, func(tx pgx.Tx) error {
+		var secret *string
+		var enabled bool
+		var lastStep int64
+		err := tx.QueryRow(ctx, `
+			SELECT totp_secret, totp_enabled, totp_last_step FROM users WHERE id = $1 FOR UPDATE
+		`, userID).Scan(&secret, &enabled, &lastStep)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return ErrUserNotFound
+			}
+			return fmt.Errorf("query user: %w", err)
+		}
+		if enabled {
+			return ErrMFAAlreadyEnabled
+		}
+		if secret == nil {
+			return ErrMFANotEnrolled
+		}
+
+		step, ok := verifyTOTP(*secret, code, time.Now(), s.mfa.Skew, lastStep)
+		if !ok {
+			return ErrInvalidMFACode
+		}
+		_, err = tx.Exec(ctx, `
+			UPDATE users SET totp_enabled = TRUE, totp_last_step = $2 WHERE id = $1
+		`, userID, step)
+		if err != nil {
+			return fmt.Errorf("enable totp: %w", err)
+		}
+
+		codes, err = s.replaceRecoveryCodes(ctx, tx, userID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.record(ctx, audit.Entry{Action: audit.ActionMFAEnable, UserID: userID})
+	return codes, nil
+}
+
+// DisableTOTP выключает двухфакторную аутентификацию, code - текущий код TOTP или код восстановления
+func (s *Service) DisableTOTP(ctx context.Context, userID int64, code string) error {
+	var codeErr error
+	err := s.inTx(ctx, func(tx pgx.Tx) error {
+		codeErr = s.checkMFA(ctx, tx, userID, code)
+		if errors.Is(codeErr, ErrInvalidMFACode) {
+			// неудачную попытку нужно зафиксировать
+			return nil
+		}
+		if codeErr != nil {
+			return codeErr
+		}
+		_, err := tx.Exec(ctx, `
+			UPDATE users SET totp_secret = NULL, totp_enabled = FALSE, totp_last_step = 0 WHERE id = $1
+		`, userID)
+		if err != nil {
+			return fmt.Errorf("disable totp: %w", err)
+		}
+		_, err = tx.Exec(ctx, `DELETE FROM recovery_codes WHERE userId = $1`, userID)
+		if err != nil {
+			return fmt.Errorf("delete recovery codes: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if codeErr != nil {
+		return codeErr
+	}
+	s.record(ctx, audit.Entry{Action: audit.ActionMFADisable, UserID: userID})
+	return nil
+}
+
+// LoginMFA - второй шаг входа: обменивает mfa-токен из Login и код TOTP (или код восстановления) на пару токенов
+func (s *Service) LoginMFA(ctx context.Context, mfaToken string, code string) (*Tokens, error) {
+	var tokens *Tokens
+	var codeErr error
+	var userID int64
+	err := s.inTx(ctx, func(tx pgx.Tx) error {
+		var attempts int
+		var expire time.Time
+		err := tx.QueryRow(ctx, `
+			SELECT userId, attempts, expire FROM mfa_tokens WHERE id = $1 FOR UPDATE
+		`, mfaToken).Scan(&userID, &attempts, &expire)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return ErrInvalidMFAToken
+			}
+			return fmt.Errorf("query mfa token: %w", err)
+		}
+		if !time.Now().Before(expire) || attempts >= maxMFAAttempts {
+			return ErrInvalidMFAToken
+		}
+
+		err = s.checkMFA(ctx, tx, userID, code)
+		if errors.Is(err, ErrInvalidMFACode) {
+			// счётчик попыток нужно зафиксировать, поэтому ошибку возвращаем после транзакции
+			codeErr = err
+			_, err = tx.Exec(ctx, `UPDATE mfa_tokens SET attempts = attempts + 1 WHERE id = $1`, mfaToken)
+			if err != nil {
+				return fmt.Errorf("update mfa token: %w", err)
+			}
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(ctx, `DELETE FROM mfa_tokens WHERE id = $1`, mfaToken)
+		if err != nil {
+			return fmt.Errorf("delete mfa token: %w", err)
+		}
+		tokens, err = s.issueTx(ctx, tx, userID, uuid.New().String())
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if codeErr != nil {
+		s.record(ctx, audit.Entry{Action: audit.ActionLoginMFAFailure, UserID: userID})
+		return nil, codeErr
+	}
+	s.record(ctx, audit.Entry{Action: audit.ActionLogin, UserID: userID, Details: map[string]string{"mfa": "true"}})
+	return tokens, nil
+}
+
+// pendingMFA создаёт mfa-токен: он не даёт доступа ни к чему, кроме LoginMFA
+func (s *Service) pendingMFA(ctx context.Context, userID int64) (*Tokens, error) {
+	token := uuid.New().String()
+	_, err := s.pool.Exec(ctx, `
+		INSERT INTO mfa_tokens (id, userId, expire) VALUES ($1, $2, $3)
+	`, token, userID, time.Now().Add(s.mfa.PendingTTL))
+	if err != nil {
+		return nil, fmt.Errorf("insert mfa token: %w", err)
+	}
+	return &Tokens{MFAToken: token, ExpiresIn: s.mfa.PendingTTL}, nil
+}
+
+// checkMFA проверяет код с ограничением на перебор по пользователю: попытка засчитывается до проверки,
+// поэтому неверный код нужно зафиксировать вместе с транзакцией. Новый mfa-токен (после ввода пароля)
+// счётчик не сбрасывает, сбрасывает только верный код.
+func (s *Service) checkMFA(ctx context.Context, tx pgx.Tx, userID int64, code string) error {
+	keys := []string{mfaKey(userID)}
+	if err := s.acquireTx(ctx, tx, keys); err != nil {
+		return err
+	}
+	if err := s.verifyMFA(ctx, tx, userID, code); err != nil {
+		return err
+	}
+	return s.releaseTx(ctx, tx, keys)
+}
+
+// verifyMFA проверяет код TOTP или (если код не похож на TOTP) одноразовый код восстановления
+func (s *Service) verifyMFA(ctx context.Context, tx pgx.Tx, userID int64, code string) error {
+	var secret *string
+	var enabled bool
+	var lastStep int64
+	err := tx.QueryRow(ctx, `
+		SELECT totp_secret, totp_enabled, totp_last_step FROM users WHERE id = $1 FOR UPDATE
+	`, userID).Scan(&secret, &enabled, &lastStep)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrUserNotFound
+		}
+		return fmt.Errorf("query user: %w", err)
+	}
+	if !enabled || secret == nil {
+		return ErrMFANotEnrolled
+	}
+
+	code = strings.TrimSpace(code)
+	if len(code) == totpDigits {
+		step, ok := verifyTOTP(*secret, code, time.Now(), s.mfa.Skew, lastStep)
+		if !ok {
+			return ErrInvalidMFACode
+		}
+		// запоминаем интервал, чтобы перехваченный код нельзя было использовать повторно
+		_, err = tx.Exec(ctx, `UPDATE users SET totp_last_step = $2 WHERE id = $1`, userID, step)
+		if err != nil {
+			return fmt.Errorf("update totp step: %w", err)
+		}
+		return nil
+	}
+
+	tag, err := tx.Exec(ctx, `
+		DELETE FROM recovery_codes WHERE userId = $1 AND hash = $2
+	`, userID, hashRecoveryCode(code))
+	if err != nil {
+		return fmt.Errorf("delete recovery code: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrInvalidMFACode
+	}
+	return nil
+}
+
+func (s *Service) replaceRecoveryCodes(ctx context.Context, tx pgx.Tx, userID int64) ([]string, error) {
+	_, err := tx.Exec(ctx, `DELETE FROM recovery_codes WHERE userId = $1`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("delete recovery codes: %w", err)
+	}
+
+	codes := make([]string, 0, recoveryCodesCount)
+	for i := 0; i < recoveryCodesCount; i++ {
+		code, err := newRecoveryCode()
+		if err != nil {
+			return nil, fmt.Errorf("generate recovery code: %w", err)
+		}
+		_, err = tx.Exec(ctx, `
+			INSERT INTO recovery_codes (userId, hash) VALUES ($1, $2)
+		`, userID, hashRecoveryCode(code))
+		if err != nil {
+			return nil, fmt.Errorf("insert recovery code: %w", err)
+		}
+		codes = append(codes, code)
+	}
+	return codes, nil
+}
+
+// newRecoveryCode генерирует код вида abcde-fghij (50 случайных бит)
+func newRecoveryCode() (string, error) {
+	random := make([]byte, 10)
+	if _, err := rand.Read(random); err != nil {
+		return "", err
+	}
+	code := strings.ToLower(totpEncoding.EncodeToString(random))[:10]
+	return code[:5] + "-" + code[5:], nil
+}
+
+// hashRecoveryCode: у кодов достаточно энтропии, поэтому медленный хэш (как для паролей) не нужен.
+// Регистр и дефисы не важны, чтобы код было проще ввести.
+func hashRecoveryCode(code string) string {
+	normalized := strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,71 @@
+package security
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"unicode/utf8"
+)
+
+// ErrWeakPassword заворачивают все ошибки проверки пароля политикой, текст ошибки можно показывать пользователю
+var ErrWeakPassword = errors.New("weak password")
+
+const (
+	defaultMinPasswordLength = 8
+	// bcrypt учитывает только первые 72 байта пароля
+	maxPasswordBytes = 72
+)
+
+// PasswordPolicy - требования к новым паролям
+type PasswordPolicy struct {
+	// MinLength - минимальная длина в символах
+	MinLength int
+	// Blocklist - пароли из утечек (в нижнем регистре), см. LoadBlocklist
+	Blocklist map[string]struct{}
+}
+
+// LoadBlocklist читает список запрещённых паролей: по одному на строку, пустые строки пропускаются
+func LoadBlocklist(path string) (map[string]struct{}, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	blocklist := make(map[string]struct{})
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		password := strings.TrimSpace(scanner.Text())
+		if password != "" {
+			blocklist[strings.ToLower(password)] = struct{}{}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return blocklist, nil
+}
+
+// Check проверяет пароль password пользователя login
+func (p *PasswordPolicy) Check(login string, password string) error {
+	minLength := p.MinLength
+	if minLength <= 0 {
+		minLength = defaultMinPasswordLength
+	}
+
+	if utf8.RuneCountInString(password) < minLength {
+		return fmt.Errorf("%w: password must be at least %d characters long", ErrWeakPassword, minLength)
+	}
+	if len(password) > maxPasswordBytes {
+		return fmt.Errorf("%w: password must be at most %d bytes long", ErrWeakPassword, maxPasswordBytes)
+	}
+	if strings.EqualFold(password, login) {
+		return fmt.Errorf("%w: password must not be equal to login", ErrWeakPassword)
+	}
+	if _, ok := p.Blocklist[strings.ToLower(password)]; ok {
+		return fmt.Errorf("%w: password is too common or has been leaked", ErrWeakPassword)
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package security
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPasswordPolicy_Check(t *testing.T) {
+	policy := &PasswordPolicy{
+		MinLength: 10,
+		Blocklist: map[string]struct{}{"password123": {}},
+	}
+
+	type args struct {
+		login    string
+		password string
+	}
+
+	tests := []struct {
+		name string
+		args args
+		weak bool
+	}{
+		{name: "ok", args: args{login: "admin", password: "correct horse"}},
+		{name: "too short", args: args{login: "admin", password: "short"}, weak: true},
+		{name: "length in characters", args: args{login: "admin", password: "пароль-дли"}},
+		{name: "too long", args: args{login: "admin", password: strings.Repeat("a", maxPasswordBytes+1)}, weak: true},
+		{name: "72 bytes", args: args{login: "admin", password: strings.Repeat("a", maxPasswordBytes)}},
+		{name: "equal to login", args: args{login: "administrator", password: "Administrator"}, weak: true},
+		{name: "blocklisted", args: args{login: "admin", password: "Password123"}, weak: true},
+	}
+
+	for _, tt := range tests {
+		err := policy.Check(tt.args.login, tt.args.password)
+		if got := errors.Is(err, ErrWeakPassword); got != tt.weak {
+			t.Errorf("%s: got %v, want weak %v", tt.name, err, tt.weak)
+		}
+	}
+}
+
+func TestPasswordPolicy_CheckDefaultLength(t *testing.T) {
+	policy := &PasswordPolicy{}
+	if err := policy.Check("admin", "1234567"); !errors.Is(err, ErrWeakPassword) {
+		t.Errorf("got %v, want %v", err, ErrWeakPassword)
+	}
+	if err := policy.Check("admin", "12345678"); err != nil {
+		t.Errorf("got %v, want nil", err)
+	}
+}
+
+func TestLoadBlocklist(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "blocklist.txt")
+	if err := os.WriteFile(path, []byte("Qwerty\n\n  letmein  \n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	blocklist, err := LoadBlocklist(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(blocklist) != 2 {
+		t.Errorf("got %v, want 2 passwords", blocklist)
+	}
+	for _, password := range []string{"qwerty", "letmein"} {
+		if _, ok := blocklist[password]; !ok {
+			t.Errorf("%s not in blocklist", password)
+		}
+	}
+
+	if _, err := LoadBlocklist(filepath.Join(t.TempDir(), "missing.txt")); err == nil {
+		t.Error("got nil, want error for missing file")
+	}
+}
+
+func TestIsKnownRole(t *testing.T) {
+	tests := []struct {
+		role string
+		want bool
+	}{
+		{role: RoleAdmin, want: true},
+		{role: RoleUser, want: true},
+		{role: "admin", want: false},
+		{role: "", want: false},
+	}
+
+	for _, tt := range tests {
+		if got := isKnownRole(tt.role); got != tt.want {
+			t.Errorf("%q: got %v, want %v", tt.role, got, tt.want)
+		}
+	}
+}
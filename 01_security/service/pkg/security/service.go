@@ -3,13 +3,20 @@ package security
 import (
 	"context"
 	"errors"
+	"fmt"
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v4"
 	"github.com/jackc/pgx/v4/pgxpool"
-	"golang.org/x/crypto/bcrypt"
+	"log"
+	"service/pkg/audit"
+	"sync/atomic"
+	"time"
 )
 
-var ErrUserNotFound = errors.New("user not found")
+var (
+	ErrUserNotFound    = errors.New("user not found")
+	ErrInvalidPassword = errors.New("invalid password")
+)
 
 const (
 	RoleAdmin = "ADMIN"
@@ -17,38 +24,135 @@ const (
 )
 
 type Service struct {
-	pool *pgxpool.Pool
+	pool           *pgxpool.Pool
+	accessTTL      time.Duration
+	refreshTTL     time.Duration
+	passwordPolicy *PasswordPolicy
+	// hasher - предпочтительный алгоритм: им хэшируются новые пароли, старые хэши пересчитываются при входе
+	hasher   PasswordHasher
+	throttle ThrottleConfig
+	mfa      MFAConfig
+	auditLog *audit.Log
+	// dummyHash (string) - хэш для сравнения, когда логин не найден (см. authenticate и RefreshDummyHash)
+	dummyHash atomic.Value
 }
 
 type UserDetails struct {
 	ID    int64
 	Login string
 	Roles []string
+	// MFA - включена двухфакторная аутентификация
+	MFA bool
+	// APIKeyID - ключ, по которому аутентифицирован пользователь (0 - токен сессии или пароль)
+	APIKeyID int64
+	// Scopes - роли, которыми ограничен API-ключ (nil - без ограничений)
+	Scopes []string
+	// Expire - срок действия токена доступа (нулевое время для API-ключа и пароля)
+	Expire time.Time
 	// TODO: остальные поля
 }
 
-func NewService(pool *pgxpool.Pool) *Service {
-	return &Service{pool: pool}
+type Option func(service *Service)
+
+// WithAccessTTL задаёт время жизни токена доступа
+func WithAccessTTL(ttl time.Duration) Option {
+	return func(service *Service) {
+		service.accessTTL = ttl
+	}
+}
+
+// WithRefreshTTL задаёт время жизни токена обновления
+func WithRefreshTTL(ttl time.Duration) Option {
+	return func(service *Service) {
+		service.refreshTTL = ttl
+	}
+}
+
+// WithPasswordPolicy задаёт требования к паролям при регистрации и смене пароля
+func WithPasswordPolicy(policy *PasswordPolicy) Option {
+	return func(service *Service) {
+		service.passwordPolicy = policy
+	}
+}
+
+// WithBcryptCost задаёт cost для новых хэшей, хэши с меньшим cost пересчитываются при входе
+func WithBcryptCost(cost int) Option {
+	return WithPasswordHasher(&BcryptHasher{Cost: cost})
 }
 
-// Возвращает профиль пользователя по id
+// WithPasswordHasher задаёт предпочтительный алгоритм хэширования паролей.
+// Хэши других алгоритмов (см. Hashers) по-прежнему проверяются и пересчитываются этим алгоритмом при входе.
+func WithPasswordHasher(hasher PasswordHasher) Option {
+	return func(service *Service) {
+		service.hasher = hasher
+	}
+}
+
+// WithAuditLog включает запись входов, выходов и изменений учётных записей в журнал аудита
+func WithAuditLog(auditLog *audit.Log) Option {
+	return func(service *Service) {
+		service.auditLog = auditLog
+	}
+}
+
+func NewService(pool *pgxpool.Pool, options ...Option) *Service {
+	service := &Service{
+		pool:           pool,
+		accessTTL:      defaultAccessTTL,
+		refreshTTL:     defaultRefreshTTL,
+		passwordPolicy: &PasswordPolicy{},
+		hasher:         &BcryptHasher{},
+		throttle:       ThrottleConfig{}.withDefaults(),
+		mfa:            MFAConfig{}.withDefaults(),
+	}
+	for _, option := range options {
+		option(service)
+	}
+	// пока не вызван RefreshDummyHash, фиктивный хэш считается предпочтительным алгоритмом.
+	// Ошибка возможна только при недопустимых параметрах, тогда и обычное хэширование не сработает.
+	dummyHash, _ := service.hasher.Hash([]byte(uuid.New().String()))
+	service.dummyHash.Store(dummyHash)
+	return service
+}
+
+// Возвращает профиль пользователя по токену доступа или API-ключу
 func (s *Service) UserDetails(ctx context.Context, id *string) (interface{}, error) {
+	if id != nil && isAPIKey(*id) {
+		return s.apiKeyDetails(ctx, *id)
+	}
+
 	details := &UserDetails{}
 	err := s.pool.QueryRow(ctx, `
-		SELECT u.id, u.login, u.roles FROM tokens t JOIN users u ON t.userId = u.id WHERE t.id = $1
-	`, id).Scan(&details.ID, &details.Login, &details.Roles)
+		SELECT u.id, u.login, u.roles, t.expire FROM tokens t JOIN users u ON t.userId = u.id WHERE t.id = $1
+	`, id).Scan(&details.ID, &details.Login, &details.Roles, &details.Expire)
 	if err != nil {
-		if err != pgx.ErrNoRows {
+		if errors.Is(err, pgx.ErrNoRows) {
 			return nil, ErrUserNotFound
 		}
-		// в ДЗ научимся заворачивать ошибки
-		return nil, err
+		return nil, fmt.Errorf("query user: %w", err)
+	}
+
+	if !time.Now().Before(details.Expire) {
+		return nil, ErrTokenExpired
 	}
 
 	return details, nil
 }
 
-// Проверяет, есть ли у пользователя соответствующая роль
+// Возвращает профиль пользователя по логину и паролю (для Basic-аутентификации), client - IP-адрес клиента.
+// С включённой двухфакторной аутентификацией пароля недостаточно - возвращается ErrMFARequired.
+func (s *Service) UserDetailsByPassword(ctx context.Context, login string, password string, client string) (interface{}, error) {
+	details, err := s.authenticate(ctx, login, password, client)
+	if err != nil {
+		return nil, err
+	}
+	if details.MFA {
+		return nil, ErrMFARequired
+	}
+	return details, nil
+}
+
+// Проверяет, есть ли у пользователя соответствующая роль (для API-ключа - только среди ролей ключа)
 func (s *Service) HasAnyRole(ctx context.Context, userDetails interface{}, roles ...string) bool {
 	details, ok := userDetails.(*UserDetails)
 	if !ok {
@@ -57,7 +161,7 @@ func (s *Service) HasAnyRole(ctx context.Context, userDetails interface{}, roles
 
 	for _, role := range roles {
 		for _, r := range details.Roles {
-			if role == r {
+			if role == r && (details.Scopes == nil || contains(details.Scopes, r)) {
 				return true
 			}
 		}
@@ -66,31 +170,58 @@ func (s *Service) HasAnyRole(ctx context.Context, userDetails interface{}, roles
 	return false
 }
 
-func (s *Service) Login(ctx context.Context, login string, password string) (*string, error) {
-	var userID int64
-	var hash []byte
-	err := s.pool.QueryRow(ctx, `
-		SELECT id, password FROM users WHERE login = $1
-	`, login).Scan(&userID, &hash)
+// Login проверяет пароль и выдаёт пару токенов (доступа и обновления) для новой сессии, client - IP-адрес клиента.
+// Если включена двухфакторная аутентификация, вместо пары токенов возвращается только Tokens.MFAToken
+// для второго шага (LoginMFA).
+func (s *Service) Login(ctx context.Context, login string, password string, client string) (*Tokens, error) {
+	details, err := s.authenticate(ctx, login, password, client)
 	if err != nil {
-		if err != pgx.ErrNoRows {
-			return nil, ErrUserNotFound
-		}
-		// в ДЗ научимся заворачивать ошибки
+		s.record(ctx, audit.Entry{
+			Action:  audit.ActionLoginFailure,
+			Login:   login,
+			IP:      client,
+			Details: map[string]string{"reason": failureReason(err)},
+		})
 		return nil, err
 	}
+	if details.MFA {
+		tokens, err := s.pendingMFA(ctx, details.ID)
+		if err != nil {
+			return nil, err
+		}
+		s.record(ctx, audit.Entry{Action: audit.ActionLoginMFARequired, UserID: details.ID, Login: login, IP: client})
+		return tokens, nil
+	}
 
-	err = bcrypt.CompareHashAndPassword(hash, []byte(password))
+	tokens, err := s.issue(ctx, details.ID, uuid.New().String())
 	if err != nil {
 		return nil, err
 	}
+	s.record(ctx, audit.Entry{Action: audit.ActionLogin, UserID: details.ID, Login: login, IP: client})
+	return tokens, nil
+}
 
-	token := uuid.New().String()
-	_, err = s.pool.Exec(ctx, `INSERT INTO tokens (id, userId) VALUES ($1, $2)`, token, userID)
-	if err != nil {
-		// в ДЗ научимся заворачивать ошибки
-		return nil, err
+// record пишет запись в журнал аудита, если он подключён.
+// Ошибка записи не отменяет уже выполненное действие, поэтому только пишется в журнал.
+func (s *Service) record(ctx context.Context, entry audit.Entry) {
+	if s.auditLog == nil {
+		return
+	}
+	if err := s.auditLog.Record(ctx, entry); err != nil {
+		log.Printf("can't record audit entry %s: %v", entry.Action, err)
 	}
+}
 
-	return &token, nil
+// failureReason - причина неудачного входа для журнала аудита
+func failureReason(err error) string {
+	switch {
+	case errors.Is(err, ErrTooManyAttempts):
+		return attemptThrottled
+	case errors.Is(err, ErrUserNotFound):
+		return attemptUnknownLogin
+	case errors.Is(err, ErrInvalidPassword):
+		return attemptInvalidPassword
+	default:
+		return "error"
+	}
 }
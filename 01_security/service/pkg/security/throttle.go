@@ -0,0 +1,320 @@
+package security
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v4"
+	"golang.org/x/crypto/bcrypt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrTooManyAttempts = errors.New("too many failed login attempts")
+
+// ThrottledError - вход временно запрещён из-за неудачных попыток, повторить можно через RetryAfter
+type ThrottledError struct {
+	RetryAfter time.Duration
+}
+
+func (e *ThrottledError) Error() string {
+	return fmt.Sprintf("%v, retry after %v", ErrTooManyAttempts, e.RetryAfter)
+}
+
+func (e *ThrottledError) Is(target error) bool {
+	return target == ErrTooManyAttempts
+}
+
+// Причины, записываемые в login_attempts
+const (
+	attemptSuccess         = "success"
+	attemptUnknownLogin    = "unknown_login"
+	attemptInvalidPassword = "invalid_password"
+	attemptThrottled       = "throttled"
+)
+
+// ThrottleConfig - защита от перебора паролей. Неудачные попытки считаются отдельно по логину и по IP клиента
+// (проверки текущего пароля при смене и кодов 2FA - по пользователю, с лимитом MaxLoginFailures):
+// первые FreeFailures попыток не ограничиваются, дальше пауза перед следующей попыткой растёт вдвое
+// (от BaseDelay до MaxDelay), после Max*Failures вход блокируется на Lockout.
+// Счётчик сбрасывается после успешного входа (для логина) или через Window без неудачных попыток.
+// Попытка засчитывается как неудачная до проверки пароля и отменяется после успешной проверки,
+// поэтому одновременные запросы не могут проскочить блокировку.
+type ThrottleConfig struct {
+	FreeFailures     int
+	MaxLoginFailures int
+	MaxIPFailures    int
+	BaseDelay        time.Duration
+	MaxDelay         time.Duration
+	Lockout          time.Duration
+	Window           time.Duration
+}
+
+// WithThrottle задаёт параметры защиты от перебора паролей
+func WithThrottle(config ThrottleConfig) Option {
+	return func(service *Service) {
+		service.throttle = config.withDefaults()
+	}
+}
+
+func (c ThrottleConfig) withDefaults() ThrottleConfig {
+	if c.FreeFailures <= 0 {
+		c.FreeFailures = 3
+	}
+	if c.MaxLoginFailures <= 0 {
+		c.MaxLoginFailures = 10
+	}
+	if c.MaxIPFailures <= 0 {
+		c.MaxIPFailures = 50
+	}
+	if c.BaseDelay <= 0 {
+		c.BaseDelay = time.Second
+	}
+	if c.MaxDelay <= 0 {
+		c.MaxDelay = time.Minute
+	}
+	if c.Lockout <= 0 {
+		c.Lockout = 15 * time.Minute
+	}
+	if c.Window <= 0 {
+		c.Window = time.Hour
+	}
+	return c
+}
+
+// delay возвращает, на сколько блокируется вход после failures неудачных попыток подряд
+func (c ThrottleConfig) delay(failures int, max int) time.Duration {
+	if failures >= max {
+		return c.Lockout
+	}
+	if failures <= c.FreeFailures {
+		return 0
+	}
+	delay := c.BaseDelay
+	for i := c.FreeFailures + 1; i < failures && delay < c.MaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > c.MaxDelay {
+		delay = c.MaxDelay
+	}
+	return delay
+}
+
+// authenticate проверяет логин и пароль с учётом ограничений на перебор и записывает попытку в login_attempts.
+// client - IP-адрес клиента (пустая строка - неизвестен).
+func (s *Service) authenticate(ctx context.Context, login string, password string, client string) (*UserDetails, error) {
+	keys := []string{loginKey(login)}
+	if client != "" {
+		keys = append(keys, ipKey(client))
+	}
+
+	err := s.acquire(ctx, keys)
+	if errors.Is(err, ErrTooManyAttempts) {
+		s.recordAttempt(ctx, login, client, attemptThrottled)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	details := &UserDetails{}
+	var hash string
+	err = s.pool.QueryRow(ctx, `
+		SELECT id, login, password, roles, totp_enabled FROM users WHERE login = $1
+	`, login).Scan(&details.ID, &details.Login, &hash, &details.Roles, &details.MFA)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("query user: %w", err)
+	}
+
+	if errors.Is(err, pgx.ErrNoRows) {
+		// Сравнение с фиктивным хэшем выравнивает время ответа: иначе по нему видно, существует ли логин.
+		// Фиктивный хэш посчитан тем же алгоритмом и с теми же параметрами, что и большинство хэшей в users
+		// (см. RefreshDummyHash), поэтому время совпадает, пока хэши не пересчитаны при входе.
+		_ = VerifyPassword(s.dummyHash.Load().(string), []byte(password))
+		s.recordAttempt(ctx, login, client, attemptUnknownLogin)
+		return nil, ErrUserNotFound
+	}
+
+	err = s.checkPassword(ctx, details.ID, hash, password)
+	if err != nil {
+		if errors.Is(err, ErrInvalidPassword) {
+			s.recordAttempt(ctx, login, client, attemptInvalidPassword)
+		}
+		return nil, err
+	}
+
+	s.release(ctx, keys)
+	s.recordAttempt(ctx, login, client, attemptSuccess)
+	return details, nil
+}
+
+// RefreshDummyHash пересчитывает фиктивный хэш алгоритмом и с параметрами самых распространённых хэшей в users.
+// Вызывается при старте и периодически из Janitor: при смене APP_PASSWORD_HASH хэши пересчитываются постепенно.
+func (s *Service) RefreshDummyHash(ctx context.Context) error {
+	var sample string
+	err := s.pool.QueryRow(ctx, `
+		SELECT password FROM users WHERE split_part(password, '$', 2) = (
+			SELECT split_part(password, '$', 2) FROM users GROUP BY 1 ORDER BY count(*) DESC LIMIT 1
+		) ORDER BY id DESC LIMIT 1
+	`).Scan(&sample)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("query password sample: %w", err)
+	}
+	return s.setDummyHash(sample)
+}
+
+func (s *Service) setDummyHash(sample string) error {
+	hasher, err := hasherFor(sample)
+	if err != nil {
+		return err
+	}
+	dummyHash, err := hasher.Hash([]byte(uuid.New().String()))
+	if err != nil {
+		return fmt.Errorf("hash dummy password: %w", err)
+	}
+	s.dummyHash.Store(dummyHash)
+	return nil
+}
+
+// hasherFor возвращает алгоритм с параметрами хэша hash: его хэши проверяются столько же времени
+func hasherFor(hash string) (PasswordHasher, error) {
+	switch {
+	case (&BcryptHasher{}).Identifies(hash):
+		cost, err := bcrypt.Cost([]byte(hash))
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrUnknownHash, err)
+		}
+		return &BcryptHasher{Cost: cost}, nil
+	case (&Argon2idHasher{}).Identifies(hash):
+		p, _, _, err := parseArgon2id(hash)
+		return p, err
+	case (&ScryptHasher{}).Identifies(hash):
+		p, _, _, err := parseScrypt(hash)
+		return p, err
+	default:
+		return nil, ErrUnknownHash
+	}
+}
+
+// acquire засчитывает попытку как неудачную по всем ключам сразу, до проверки пароля.
+// Если хотя бы по одному ключу действует блокировка, счётчики не меняются и возвращается *ThrottledError.
+func (s *Service) acquire(ctx context.Context, keys []string) error {
+	return s.inTx(ctx, func(tx pgx.Tx) error {
+		return s.acquireTx(ctx, tx, keys)
+	})
+}
+
+func (s *Service) acquireTx(ctx context.Context, tx pgx.Tx, keys []string) error {
+	now := time.Now()
+	for _, key := range keys {
+		// строка блокируется и при невыполненном WHERE, поэтому проверка и увеличение счётчика атомарны
+		var failures int
+		err := tx.QueryRow(ctx, `
+			INSERT INTO login_failures (key, failures, last_failure, blocked_until) VALUES ($1, 1, $2, $2)
+			ON CONFLICT (key) DO UPDATE SET
+				failures = CASE WHEN login_failures.last_failure < $3 THEN 1 ELSE login_failures.failures + 1 END,
+				last_failure = $2
+			WHERE login_failures.blocked_until <= $2
+			RETURNING failures
+		`, key, now, now.Add(-s.throttle.Window)).Scan(&failures)
+		if errors.Is(err, pgx.ErrNoRows) {
+			var blockedUntil time.Time
+			err = tx.QueryRow(ctx, `SELECT blocked_until FROM login_failures WHERE key = $1`, key).Scan(&blockedUntil)
+			if err != nil {
+				return fmt.Errorf("query login failures: %w", err)
+			}
+			return &ThrottledError{RetryAfter: blockedUntil.Sub(now)}
+		}
+		if err != nil {
+			return fmt.Errorf("record login failure: %w", err)
+		}
+
+		_, err = tx.Exec(ctx, `
+			UPDATE login_failures SET blocked_until = $2 WHERE key = $1
+		`, key, now.Add(s.throttle.delay(failures, s.throttle.max(key))))
+		if err != nil {
+			return fmt.Errorf("update login lockout: %w", err)
+		}
+	}
+	return nil
+}
+
+// release отменяет попытку, засчитанную acquire, после успешной проверки: счётчик логина сбрасывается,
+// из счётчика IP вычитается эта попытка. Ошибки только пишутся в журнал: проверка уже пройдена.
+func (s *Service) release(ctx context.Context, keys []string) {
+	err := s.inTx(ctx, func(tx pgx.Tx) error {
+		return s.releaseTx(ctx, tx, keys)
+	})
+	if err != nil {
+		log.Printf("can't reset login failures: %v", err)
+	}
+}
+
+func (s *Service) releaseTx(ctx context.Context, tx pgx.Tx, keys []string) error {
+	now := time.Now()
+	for _, key := range keys {
+		if !strings.HasPrefix(key, "ip:") {
+			_, err := tx.Exec(ctx, `DELETE FROM login_failures WHERE key = $1`, key)
+			if err != nil {
+				return fmt.Errorf("delete login failures: %w", err)
+			}
+			continue
+		}
+
+		var failures int
+		err := tx.QueryRow(ctx, `
+			UPDATE login_failures SET failures = greatest(failures - 1, 0) WHERE key = $1 RETURNING failures
+		`, key).Scan(&failures)
+		if errors.Is(err, pgx.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("update login failures: %w", err)
+		}
+		_, err = tx.Exec(ctx, `
+			UPDATE login_failures SET blocked_until = least(blocked_until, $2) WHERE key = $1
+		`, key, now.Add(s.throttle.delay(failures, s.throttle.max(key))))
+		if err != nil {
+			return fmt.Errorf("update login lockout: %w", err)
+		}
+	}
+	return nil
+}
+
+// max - после скольких неудачных попыток по ключу вход блокируется на Lockout
+func (c ThrottleConfig) max(key string) int {
+	if strings.HasPrefix(key, "ip:") {
+		return c.MaxIPFailures
+	}
+	return c.MaxLoginFailures
+}
+
+func (s *Service) recordAttempt(ctx context.Context, login string, client string, result string) {
+	_, err := s.pool.Exec(ctx, `
+		INSERT INTO login_attempts (login, ip, success, result) VALUES ($1, $2, $3, $4)
+	`, login, client, result == attemptSuccess, result)
+	if err != nil {
+		log.Printf("can't record login attempt: %v", err)
+	}
+}
+
+func loginKey(login string) string {
+	return "login:" + strings.ToLower(login)
+}
+
+func ipKey(client string) string {
+	return "ip:" + client
+}
+
+func userKey(userID int64) string {
+	return "user:" + strconv.FormatInt(userID, 10)
+}
+
+func mfaKey(userID int64) string {
+	return "mfa:" + strconv.FormatInt(userID, 10)
+}
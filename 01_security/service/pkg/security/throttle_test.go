@@ -0,0 +1,173 @@
+package security
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestThrottleConfig_delay(t *testing.T) {
+	config := ThrottleConfig{MaxDelay: 5 * time.Second}.withDefaults()
+
+	type args struct {
+		failures int
+		max      int
+	}
+
+	tests := []struct {
+		name string
+		args args
+		want time.Duration
+	}{
+		{name: "no failures", args: args{failures: 0, max: 10}, want: 0},
+		{name: "free", args: args{failures: 3, max: 10}, want: 0},
+		{name: "first delay", args: args{failures: 4, max: 10}, want: time.Second},
+		{name: "doubled", args: args{failures: 5, max: 10}, want: 2 * time.Second},
+		{name: "doubled twice", args: args{failures: 6, max: 10}, want: 4 * time.Second},
+		{name: "max delay", args: args{failures: 7, max: 10}, want: 5 * time.Second},
+		{name: "max delay stays", args: args{failures: 9, max: 10}, want: 5 * time.Second},
+		{name: "lockout", args: args{failures: 10, max: 10}, want: 15 * time.Minute},
+		{name: "after lockout", args: args{failures: 11, max: 10}, want: 15 * time.Minute},
+		{name: "ip limit", args: args{failures: 10, max: 50}, want: 5 * time.Second},
+	}
+
+	for _, tt := range tests {
+		if got := config.delay(tt.args.failures, tt.args.max); got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestThrottleConfig_max(t *testing.T) {
+	config := ThrottleConfig{}.withDefaults()
+	tests := []struct {
+		name string
+		key  string
+		want int
+	}{
+		{name: "login", key: loginKey("Admin"), want: config.MaxLoginFailures},
+		{name: "ip", key: ipKey("192.0.2.1"), want: config.MaxIPFailures},
+		{name: "password change", key: userKey(1), want: config.MaxLoginFailures},
+		{name: "mfa", key: mfaKey(1), want: config.MaxLoginFailures},
+	}
+
+	for _, tt := range tests {
+		if got := config.max(tt.key); got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestLoginKey(t *testing.T) {
+	if loginKey("Admin") != loginKey("admin") {
+		t.Errorf("login key must not depend on case: %s, %s", loginKey("Admin"), loginKey("admin"))
+	}
+	if loginKey("1") == userKey(1) || userKey(1) == mfaKey(1) {
+		t.Error("keys of different kinds must not collide")
+	}
+}
+
+func TestFailureReason(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{name: "throttled", err: &ThrottledError{RetryAfter: time.Second}, want: attemptThrottled},
+		{name: "unknown login", err: ErrUserNotFound, want: attemptUnknownLogin},
+		{name: "invalid password", err: fmt.Errorf("verify: %w", ErrInvalidPassword), want: attemptInvalidPassword},
+		{name: "other", err: errors.New("connection refused"), want: "error"},
+	}
+
+	for _, tt := range tests {
+		if got := failureReason(tt.err); got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+// Для неизвестного логина проверяется фиктивный хэш того же алгоритма и с теми же параметрами,
+// что и большинство хэшей в users, даже если предпочтительный алгоритм другой.
+// Проверка должна занимать столько же, сколько проверка настоящего хэша.
+func TestDummyHashTiming(t *testing.T) {
+	type args struct {
+		preferred PasswordHasher
+		stored    PasswordHasher
+	}
+
+	tests := []struct {
+		name string
+		args args
+	}{
+		{name: "bcrypt", args: args{preferred: &BcryptHasher{Cost: 8}, stored: &BcryptHasher{Cost: 8}}},
+		{name: "argon2id", args: args{preferred: &Argon2idHasher{Time: 1, Memory: 8 * 1024, Threads: 1}, stored: &Argon2idHasher{Time: 1, Memory: 8 * 1024, Threads: 1}}},
+		{name: "scrypt", args: args{preferred: &ScryptHasher{LogN: 12}, stored: &ScryptHasher{LogN: 12}}},
+		{name: "bcrypt stored, argon2id preferred", args: args{preferred: &Argon2idHasher{Time: 1, Memory: 1024, Threads: 1}, stored: &BcryptHasher{Cost: 8}}},
+		{name: "scrypt stored, bcrypt preferred", args: args{preferred: &BcryptHasher{Cost: 4}, stored: &ScryptHasher{LogN: 12}}},
+	}
+
+	for _, tt := range tests {
+		hash, err := tt.args.stored.Hash([]byte("secret"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		service := NewService(nil, WithPasswordHasher(tt.args.preferred))
+		if err := service.setDummyHash(hash); err != nil {
+			t.Fatal(err)
+		}
+		dummyHash := service.dummyHash.Load().(string)
+		if NeedsUpgrade(tt.args.stored, dummyHash) {
+			t.Errorf("%s: dummy hash %v differs from stored hash parameters", tt.name, dummyHash)
+			continue
+		}
+
+		dummy := medianDuration(func() { _ = VerifyPassword(dummyHash, []byte("guess")) })
+		actual := medianDuration(func() { _ = VerifyPassword(hash, []byte("guess")) })
+		if ratio := float64(dummy) / float64(actual); ratio < 0.5 || ratio > 2 {
+			t.Errorf("%s: dummy %v, real %v", tt.name, dummy, actual)
+		}
+	}
+}
+
+func TestHasherFor(t *testing.T) {
+	tests := []struct {
+		name   string
+		hasher PasswordHasher
+	}{
+		{name: "bcrypt", hasher: &BcryptHasher{Cost: 5}},
+		{name: "argon2id", hasher: &Argon2idHasher{Time: 2, Memory: 2048, Threads: 3}},
+		{name: "scrypt", hasher: &ScryptHasher{LogN: 11, R: 4, P: 2}},
+	}
+
+	for _, tt := range tests {
+		hash, err := tt.hasher.Hash([]byte("secret"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := hasherFor(hash)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if !reflect.DeepEqual(got, tt.hasher) {
+			t.Errorf("%s: got %+v, want %+v", tt.name, got, tt.hasher)
+		}
+	}
+
+	if _, err := hasherFor("plain text"); !errors.Is(err, ErrUnknownHash) {
+		t.Errorf("got %v, want %v", err, ErrUnknownHash)
+	}
+}
+
+func medianDuration(fn func()) time.Duration {
+	durations := make([]time.Duration, 7)
+	for i := range durations {
+		start := time.Now()
+		fn()
+		durations[i] = time.Since(start)
+	}
+	sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+	return durations[len(durations)/2]
+}
@@ -0,0 +1,254 @@
+package security
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v4"
+	"log"
+	"service/pkg/audit"
+	"time"
+)
+
+var (
+	ErrTokenExpired        = errors.New("token expired")
+	ErrInvalidRefreshToken = errors.New("invalid refresh token")
+	ErrRefreshTokenExpired = errors.New("refresh token expired")
+	// ErrRefreshTokenReused - токен обновления использован повторно (скорее всего, украден),
+	// вся сессия (семейство токенов) отозвана
+	ErrRefreshTokenReused = errors.New("refresh token reused")
+)
+
+const (
+	defaultAccessTTL  = time.Hour
+	defaultRefreshTTL = 30 * 24 * time.Hour
+)
+
+// Tokens - токены, выдаваемые при входе и обновлении
+type Tokens struct {
+	Access  string
+	Refresh string
+	// ExpiresIn - время жизни токена доступа (или MFAToken)
+	ExpiresIn time.Duration
+	// MFAToken - токен второго шага входа, если включена двухфакторная аутентификация (тогда Access и Refresh пустые)
+	MFAToken string
+}
+
+// TokenReuseError - повторно использован токен обновления пользователя UserID, сессия отозвана
+type TokenReuseError struct {
+	UserID int64
+}
+
+func (e *TokenReuseError) Error() string {
+	return ErrRefreshTokenReused.Error()
+}
+
+func (e *TokenReuseError) Is(target error) bool {
+	return target == ErrRefreshTokenReused
+}
+
+// Refresh выдаёт новую пару токенов в обмен на токен обновления (ротация: старый токен больше не действует).
+// Повторное использование токена обновления отзывает всю сессию, к которой он относится.
+func (s *Service) Refresh(ctx context.Context, refreshToken string) (*Tokens, error) {
+	var tokens *Tokens
+	var reused bool
+	var userID int64
+	err := s.inTx(ctx, func(tx pgx.Tx) error {
+		var family string
+		var used bool
+		var expire time.Time
+		err := tx.QueryRow(ctx, `
+			SELECT userId, family, used, expire FROM refresh_tokens WHERE id = $1 FOR UPDATE
+		`, refreshToken).Scan(&userID, &family, &used, &expire)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return ErrInvalidRefreshToken
+			}
+			return fmt.Errorf("query refresh token: %w", err)
+		}
+
+		if used {
+			// изменения нужно зафиксировать, поэтому ошибку возвращаем уже после транзакции
+			reused = true
+			return s.revokeFamily(ctx, tx, family)
+		}
+		if !time.Now().Before(expire) {
+			return ErrRefreshTokenExpired
+		}
+
+		_, err = tx.Exec(ctx, `UPDATE refresh_tokens SET used = TRUE WHERE id = $1`, refreshToken)
+		if err != nil {
+			return fmt.Errorf("update refresh token: %w", err)
+		}
+
+		tokens, err = s.issueTx(ctx, tx, userID, family)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if reused {
+		s.record(ctx, audit.Entry{Action: audit.ActionTokenReuse, UserID: userID})
+		return nil, &TokenReuseError{UserID: userID}
+	}
+	return tokens, nil
+}
+
+// Logout завершает сессию, к которой относится токен доступа (удаляет её токены доступа и обновления)
+func (s *Service) Logout(ctx context.Context, token string) error {
+	var userID int64
+	err := s.inTx(ctx, func(tx pgx.Tx) error {
+		var family string
+		err := tx.QueryRow(ctx, `SELECT userId, family FROM tokens WHERE id = $1`, token).Scan(&userID, &family)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return ErrUserNotFound
+			}
+			return fmt.Errorf("query token: %w", err)
+		}
+		return s.revokeFamily(ctx, tx, family)
+	})
+	if err != nil {
+		return err
+	}
+	s.record(ctx, audit.Entry{Action: audit.ActionLogout, UserID: userID})
+	return nil
+}
+
+// LogoutAll завершает все сессии пользователя
+func (s *Service) LogoutAll(ctx context.Context, userID int64) error {
+	err := s.inTx(ctx, func(tx pgx.Tx) error {
+		_, err := tx.Exec(ctx, `DELETE FROM tokens WHERE userId = $1`, userID)
+		if err != nil {
+			return fmt.Errorf("delete tokens: %w", err)
+		}
+		_, err = tx.Exec(ctx, `DELETE FROM refresh_tokens WHERE userId = $1`, userID)
+		if err != nil {
+			return fmt.Errorf("delete refresh tokens: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	s.record(ctx, audit.Entry{Action: audit.ActionLogoutAll, UserID: userID})
+	return nil
+}
+
+// PurgeExpired удаляет просроченные токены (доступа, обновления, mfa) и устаревшие счётчики неудачных входов,
+// возвращает количество удалённых строк
+func (s *Service) PurgeExpired(ctx context.Context) (int64, error) {
+	tokens, err := s.pool.Exec(ctx, `DELETE FROM tokens WHERE expire <= CURRENT_TIMESTAMP`)
+	if err != nil {
+		return 0, fmt.Errorf("delete tokens: %w", err)
+	}
+	refreshTokens, err := s.pool.Exec(ctx, `DELETE FROM refresh_tokens WHERE expire <= CURRENT_TIMESTAMP`)
+	if err != nil {
+		return 0, fmt.Errorf("delete refresh tokens: %w", err)
+	}
+	mfaTokens, err := s.pool.Exec(ctx, `DELETE FROM mfa_tokens WHERE expire <= CURRENT_TIMESTAMP`)
+	if err != nil {
+		return 0, fmt.Errorf("delete mfa tokens: %w", err)
+	}
+	// счётчики, по которым нет ни блокировки, ни недавних попыток, больше не нужны
+	failures, err := s.pool.Exec(ctx, `
+		DELETE FROM login_failures WHERE blocked_until <= CURRENT_TIMESTAMP AND last_failure < $1
+	`, time.Now().Add(-s.throttle.Window))
+	if err != nil {
+		return 0, fmt.Errorf("delete login failures: %w", err)
+	}
+	return tokens.RowsAffected() + refreshTokens.RowsAffected() + mfaTokens.RowsAffected() + failures.RowsAffected(), nil
+}
+
+// Janitor раз в interval вызывает PurgeExpired и RefreshDummyHash, пока не отменён ctx:
+//
+//	go securitySvc.Janitor(ctx, time.Hour)
+func (s *Service) Janitor(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.RefreshDummyHash(ctx); err != nil {
+				log.Printf("can't refresh dummy hash: %v", err)
+			}
+			purged, err := s.PurgeExpired(ctx)
+			if err != nil {
+				log.Printf("can't purge expired rows: %v", err)
+				continue
+			}
+			if purged != 0 {
+				log.Printf("purged %d expired rows", purged)
+			}
+		}
+	}
+}
+
+// issue выдаёт пару токенов для сессии family
+func (s *Service) issue(ctx context.Context, userID int64, family string) (*Tokens, error) {
+	var tokens *Tokens
+	err := s.inTx(ctx, func(tx pgx.Tx) (err error) {
+		tokens, err = s.issueTx(ctx, tx, userID, family)
+		return err
+	})
+	return tokens, err
+}
+
+func (s *Service) issueTx(ctx context.Context, tx pgx.Tx, userID int64, family string) (*Tokens, error) {
+	now := time.Now()
+	tokens := &Tokens{
+		Access:    uuid.New().String(),
+		Refresh:   uuid.New().String(),
+		ExpiresIn: s.accessTTL,
+	}
+
+	_, err := tx.Exec(ctx, `
+		INSERT INTO tokens (id, userId, family, expire) VALUES ($1, $2, $3, $4)
+	`, tokens.Access, userID, family, now.Add(s.accessTTL))
+	if err != nil {
+		return nil, fmt.Errorf("insert token: %w", err)
+	}
+	_, err = tx.Exec(ctx, `
+		INSERT INTO refresh_tokens (id, userId, family, expire) VALUES ($1, $2, $3, $4)
+	`, tokens.Refresh, userID, family, now.Add(s.refreshTTL))
+	if err != nil {
+		return nil, fmt.Errorf("insert refresh token: %w", err)
+	}
+	return tokens, nil
+}
+
+func (s *Service) revokeFamily(ctx context.Context, tx pgx.Tx, family string) error {
+	_, err := tx.Exec(ctx, `DELETE FROM tokens WHERE family = $1`, family)
+	if err != nil {
+		return fmt.Errorf("delete tokens: %w", err)
+	}
+	_, err = tx.Exec(ctx, `DELETE FROM refresh_tokens WHERE family = $1`, family)
+	if err != nil {
+		return fmt.Errorf("delete refresh tokens: %w", err)
+	}
+	return nil
+}
+
+// inTx выполняет fn в транзакции: фиксирует её, если fn вернула nil, иначе откатывает
+func (s *Service) inTx(ctx context.Context, fn func(tx pgx.Tx) error) error {
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin: %w", err)
+	}
+	defer func() {
+		// после Commit откат ничего не делает
+		_ = tx.Rollback(ctx)
+	}()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("commit: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,35 @@
+package security
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestTokenReuseError(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		reused bool
+		userID int64
+	}{
+		{name: "reuse", err: &TokenReuseError{UserID: 1}, reused: true, userID: 1},
+		{name: "wrapped", err: fmt.Errorf("refresh: %w", &TokenReuseError{UserID: 2}), reused: true, userID: 2},
+		{name: "sentinel only", err: ErrRefreshTokenReused, reused: true},
+		{name: "expired", err: ErrRefreshTokenExpired},
+	}
+
+	for _, tt := range tests {
+		if got := errors.Is(tt.err, ErrRefreshTokenReused); got != tt.reused {
+			t.Errorf("%s: got reused %v, want %v", tt.name, got, tt.reused)
+		}
+		var reuse *TokenReuseError
+		var got int64
+		if errors.As(tt.err, &reuse) {
+			got = reuse.UserID
+		}
+		if got != tt.userID {
+			t.Errorf("%s: got user %v, want %v", tt.name, got, tt.userID)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package security
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Параметры TOTP (RFC 6238) - значения по умолчанию, которые понимают все приложения-аутентификаторы
+const (
+	totpPeriod     = 30 * time.Second
+	totpDigits     = 6
+	totpSecretSize = 20
+)
+
+var totpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// newTOTPSecret генерирует секрет в кодировке base32 (в таком виде его принимают приложения)
+func newTOTPSecret() (string, error) {
+	secret := make([]byte, totpSecretSize)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return totpEncoding.EncodeToString(secret), nil
+}
+
+// totpURI возвращает URI для QR-кода: otpauth://totp/Issuer:login?secret=...&issuer=Issuer
+func totpURI(issuer string, login string, secret string) string {
+	query := url.Values{}
+	query.Set("secret", secret)
+	query.Set("issuer", issuer)
+	query.Set("algorithm", "SHA1")
+	query.Set("digits", fmt.Sprint(totpDigits))
+	query.Set("period", fmt.Sprint(int(totpPeriod/time.Second)))
+	return (&url.URL{
+		Scheme: "otpauth",
+		Host:   "totp",
+		Path:   "/" + issuer + ":" + login,
+		// приложения ожидают пробел в виде %20, а не +
+		RawQuery: strings.ReplaceAll(query.Encode(), "+", "%20"),
+	}).String()
+}
+
+// totpStep - номер 30-секундного интервала для момента t
+func totpStep(t time.Time) int64 {
+	return t.Unix() / int64(totpPeriod/time.Second)
+}
+
+// totpCode вычисляет код для интервала step (HOTP из RFC 4226 со счётчиком step)
+func totpCode(secret []byte, step int64) string {
+	counter := make([]byte, 8)
+	binary.BigEndian.PutUint64(counter, uint64(step))
+
+	mac := hmac.New(sha1.New, secret)
+	mac.Write(counter)
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	modulo := uint32(1)
+	for i := 0; i < totpDigits; i++ {
+		modulo *= 10
+	}
+	return fmt.Sprintf("%0*d", totpDigits, value%modulo)
+}
+
+// verifyTOTP ищет code в интервалах now±skew, пропуская интервалы не позже lastStep (код нельзя использовать дважды).
+// Возвращает интервал совпавшего кода.
+func verifyTOTP(encodedSecret string, code string, now time.Time, skew int, lastStep int64) (int64, bool) {
+	secret, err := totpEncoding.DecodeString(encodedSecret)
+	if err != nil || len(code) != totpDigits {
+		return 0, false
+	}
+
+	current := totpStep(now)
+	for delta := -int64(skew); delta <= int64(skew); delta++ {
+		step := current + delta
+		if step <= lastStep {
+			continue
+		}
+		if subtle.ConstantTimeCompare([]byte(totpCode(secret, step)), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+	return 0, false
+}
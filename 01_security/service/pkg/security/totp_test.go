@@ -0,0 +1,131 @@
+package security
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+// секрет из тестовых векторов RFC 6238 (приложение B), коды - последние 6 цифр
+var rfcSecret = []byte("12345678901234567890")
+
+func TestTotpCode(t *testing.T) {
+	tests := []struct {
+		time int64
+		want string
+	}{
+		{time: 59, want: "287082"},
+		{time: 1111111109, want: "081804"},
+		{time: 1111111111, want: "050471"},
+		{time: 1234567890, want: "005924"},
+		{time: 2000000000, want: "279037"},
+		{time: 20000000000, want: "353130"},
+	}
+
+	for _, tt := range tests {
+		if got := totpCode(rfcSecret, totpStep(time.Unix(tt.time, 0))); got != tt.want {
+			t.Errorf("%d: got %v, want %v", tt.time, got, tt.want)
+		}
+	}
+}
+
+func TestVerifyTOTP(t *testing.T) {
+	secret := totpEncoding.EncodeToString(rfcSecret)
+	now := time.Unix(1111111111, 0)
+	current := totpStep(now)
+
+	type args struct {
+		secret   string
+		code     string
+		skew     int
+		lastStep int64
+	}
+
+	tests := []struct {
+		name string
+		args args
+		step int64
+		ok   bool
+	}{
+		{name: "current", args: args{secret: secret, code: totpCode(rfcSecret, current), skew: 1}, step: current, ok: true},
+		{name: "previous", args: args{secret: secret, code: totpCode(rfcSecret, current-1), skew: 1}, step: current - 1, ok: true},
+		{name: "next", args: args{secret: secret, code: totpCode(rfcSecret, current+1), skew: 1}, step: current + 1, ok: true},
+		{name: "outside skew", args: args{secret: secret, code: totpCode(rfcSecret, current-2), skew: 1}},
+		{name: "no skew", args: args{secret: secret, code: totpCode(rfcSecret, current-1), skew: 0}},
+		{name: "replay", args: args{secret: secret, code: totpCode(rfcSecret, current), skew: 1, lastStep: current}},
+		{name: "older than used", args: args{secret: secret, code: totpCode(rfcSecret, current-1), skew: 1, lastStep: current}},
+		{name: "after used", args: args{secret: secret, code: totpCode(rfcSecret, current+1), skew: 1, lastStep: current}, step: current + 1, ok: true},
+		{name: "wrong code", args: args{secret: secret, code: "000000", skew: 1}},
+		{name: "wrong length", args: args{secret: secret, code: "50471", skew: 1}},
+		{name: "invalid secret", args: args{secret: "!", code: totpCode(rfcSecret, current), skew: 1}},
+	}
+
+	for _, tt := range tests {
+		step, ok := verifyTOTP(tt.args.secret, tt.args.code, now, tt.args.skew, tt.args.lastStep)
+		if ok != tt.ok || step != tt.step {
+			t.Errorf("%s: got %v %v, want %v %v", tt.name, step, ok, tt.step, tt.ok)
+		}
+	}
+}
+
+func TestTotpURI(t *testing.T) {
+	uri, err := url.Parse(totpURI("My Service", "admin", "SECRET"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if uri.Scheme != "otpauth" || uri.Host != "totp" || uri.Path != "/My Service:admin" {
+		t.Errorf("got %v", uri)
+	}
+	query := uri.Query()
+	for name, want := range map[string]string{"secret": "SECRET", "issuer": "My Service", "digits": "6", "period": "30"} {
+		if got := query.Get(name); got != want {
+			t.Errorf("%s: got %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestMFAConfig_withDefaults(t *testing.T) {
+	tests := []struct {
+		name string
+		skew int
+		want int
+	}{
+		{name: "default", skew: 0, want: 1},
+		{name: "no skew", skew: NoSkew, want: 0},
+		{name: "custom", skew: 2, want: 2},
+	}
+
+	for _, tt := range tests {
+		if got := (MFAConfig{Skew: tt.skew}).withDefaults().Skew; got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRecoveryCode(t *testing.T) {
+	code, err := newRecoveryCode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !regexp.MustCompile(`^[a-z2-7]{5}-[a-z2-7]{5}$`).MatchString(code) {
+		t.Errorf("got %v, want xxxxx-xxxxx", code)
+	}
+
+	tests := []struct {
+		name  string
+		input string
+		same  bool
+	}{
+		{name: "as issued", input: code, same: true},
+		{name: "upper case", input: strings.ToUpper(code), same: true},
+		{name: "without dash", input: " " + code[:5] + code[6:] + " ", same: true},
+		{name: "another", input: "aaaaa-aaaaa"},
+	}
+	for _, tt := range tests {
+		if same := hashRecoveryCode(tt.input) == hashRecoveryCode(code); same != tt.same {
+			t.Errorf("%s: got same %v, want %v", tt.name, same, tt.same)
+		}
+	}
+}
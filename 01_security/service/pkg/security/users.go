@@ -0,0 +1,164 @@
+package security
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/jackc/pgx/v4"
+	"log"
+	"service/pkg/audit"
+	"strings"
+)
+
+var (
+	ErrInvalidLogin = errors.New("invalid login")
+	ErrLoginTaken   = errors.New("login already taken")
+	ErrUnknownRole  = errors.New("unknown role")
+)
+
+// Roles - все роли, которые можно назначить пользователю
+var Roles = []string{RoleAdmin, RoleUser}
+
+// Register создаёт пользователя с ролью USER и возвращает его id
+func (s *Service) Register(ctx context.Context, login string, password string) (int64, error) {
+	login = strings.TrimSpace(login)
+	if login == "" {
+		return 0, ErrInvalidLogin
+	}
+	if err := s.passwordPolicy.Check(login, password); err != nil {
+		return 0, err
+	}
+
+	hash, err := s.hasher.Hash([]byte(password))
+	if err != nil {
+		return 0, fmt.Errorf("hash password: %w", err)
+	}
+
+	var userID int64
+	err = s.pool.QueryRow(ctx, `
+		INSERT INTO users (login, password, roles) VALUES ($1, $2, $3)
+		ON CONFLICT (login) DO NOTHING
+		RETURNING id
+	`, login, hash, []string{RoleUser}).Scan(&userID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return 0, ErrLoginTaken
+		}
+		return 0, fmt.Errorf("insert user: %w", err)
+	}
+	s.record(ctx, audit.Entry{Action: audit.ActionRegister, UserID: userID, Login: login})
+
+	return userID, nil
+}
+
+// ChangePassword меняет пароль после проверки текущего и завершает все сессии пользователя.
+// Неудачные проверки текущего пароля ограничиваются так же, как вход (по пользователю).
+func (s *Service) ChangePassword(ctx context.Context, userID int64, oldPassword string, newPassword string) error {
+	var login string
+	var hash string
+	err := s.pool.QueryRow(ctx, `
+		SELECT login, password FROM users WHERE id = $1
+	`, userID).Scan(&login, &hash)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrUserNotFound
+		}
+		return fmt.Errorf("query user: %w", err)
+	}
+
+	keys := []string{userKey(userID)}
+	if err := s.acquire(ctx, keys); err != nil {
+		return err
+	}
+	err = VerifyPassword(hash, []byte(oldPassword))
+	if err != nil {
+		return err
+	}
+	s.release(ctx, keys)
+
+	if err := s.passwordPolicy.Check(login, newPassword); err != nil {
+		return err
+	}
+
+	newHash, err := s.hasher.Hash([]byte(newPassword))
+	if err != nil {
+		return fmt.Errorf("hash password: %w", err)
+	}
+
+	err = s.inTx(ctx, func(tx pgx.Tx) error {
+		_, err := tx.Exec(ctx, `UPDATE users SET password = $2 WHERE id = $1`, userID, newHash)
+		if err != nil {
+			return fmt.Errorf("update password: %w", err)
+		}
+		_, err = tx.Exec(ctx, `DELETE FROM tokens WHERE userId = $1`, userID)
+		if err != nil {
+			return fmt.Errorf("delete tokens: %w", err)
+		}
+		_, err = tx.Exec(ctx, `DELETE FROM refresh_tokens WHERE userId = $1`, userID)
+		if err != nil {
+			return fmt.Errorf("delete refresh tokens: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	s.record(ctx, audit.Entry{Action: audit.ActionPasswordChange, UserID: userID, Login: login})
+	return nil
+}
+
+// SetRoles заменяет роли пользователя
+func (s *Service) SetRoles(ctx context.Context, userID int64, roles []string) error {
+	for _, role := range roles {
+		if !isKnownRole(role) {
+			return fmt.Errorf("%w: %s", ErrUnknownRole, role)
+		}
+	}
+
+	tag, err := s.pool.Exec(ctx, `UPDATE users SET roles = $2 WHERE id = $1`, userID, roles)
+	if err != nil {
+		return fmt.Errorf("update roles: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrUserNotFound
+	}
+	s.record(ctx, audit.Entry{
+		Action:  audit.ActionRolesChange,
+		UserID:  userID,
+		Details: map[string]string{"roles": strings.Join(roles, ",")},
+	})
+	return nil
+}
+
+// checkPassword сравнивает пароль с хэшем любого поддерживаемого алгоритма и, если хэш посчитан другим алгоритмом
+// или с более слабыми параметрами, пересчитывает его предпочтительным. Ошибка пересчёта не мешает входу, она только пишется в журнал.
+func (s *Service) checkPassword(ctx context.Context, userID int64, hash string, password string) error {
+	err := VerifyPassword(hash, []byte(password))
+	if err != nil {
+		return err
+	}
+	if !NeedsUpgrade(s.hasher, hash) {
+		return nil
+	}
+
+	newHash, err := s.hasher.Hash([]byte(password))
+	if err != nil {
+		log.Printf("can't rehash password: %v", err)
+		return nil
+	}
+	// условие по старому хэшу - чтобы не затереть пароль, изменённый параллельно
+	_, err = s.pool.Exec(ctx, `UPDATE users SET password = $3 WHERE id = $1 AND password = $2`, userID, hash, newHash)
+	if err != nil {
+		log.Printf("can't update rehashed password: %v", err)
+	}
+	return nil
+}
+
+func isKnownRole(role string) bool {
+	for _, known := range Roles {
+		if role == known {
+			return true
+		}
+	}
+	return false
+}
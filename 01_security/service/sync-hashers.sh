@@ -0,0 +1,34 @@
+#!/bin/sh
+
+# Копирует pkg/security/hasher.go в auth-сервисы 09, 10 и 11: они собираются из своего каталога
+# (context: services/auth) как отдельные модули и не могут импортировать пакет security.
+# С -check копии только сравниваются, при расхождении скрипт завершается с ошибкой.
+
+root=$(cd "$(dirname "$0")/../.." && pwd)
+source="$root/01_security/service/pkg/security/hasher.go"
+status=0
+
+for project in 09_micro-sync 10_micro-events 11_micro-tracing; do
+  target="$root/$project/services/auth/pkg/auth/hasher.go"
+  generated=$(mktemp)
+  {
+    echo "// Code generated by 01_security/service/sync-hashers.sh from 01_security/service/pkg/security/hasher.go. DO NOT EDIT."
+    echo "// Отличаются только пакет и ErrInvalidPass. Исправления вносить в исходный файл, затем запускать go generate."
+    echo
+    echo "//go:generate sh ../../../../../01_security/service/sync-hashers.sh"
+    echo
+    sed -e 's/^package security$/package auth/' -e 's/ErrInvalidPassword/ErrInvalidPass/g' "$source"
+  } > "$generated"
+
+  if [ "$1" = "-check" ]; then
+    if ! cmp -s "$generated" "$target"; then
+      echo "$target differs from $source, run $0" >&2
+      status=1
+    fi
+  else
+    cat "$generated" > "$target"
+  fi
+  rm -f "$generated"
+done
+
+exit $status
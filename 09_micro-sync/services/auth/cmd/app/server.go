@@ -20,7 +20,7 @@ func NewServer(authSvc *auth.Service, mux chi.Router) *Server {
 }
 
 func (s *Server) Init() error {
-	s.mux.Use(middleware.RealIP)
+	s.mux.Use(middleware.RequestID, middleware.RealIP, middleware.Logger)
 
 	s.mux.Route("/api", func(r chi.Router) {
 		r.Post("/token", s.token)
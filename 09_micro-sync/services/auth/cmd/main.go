@@ -4,6 +4,7 @@ import (
 	"auth/cmd/app"
 	"auth/pkg/auth"
 	"context"
+	"fmt"
 	"github.com/go-chi/chi"
 	"github.com/jackc/pgx/v4/pgxpool"
 	"log"
@@ -34,12 +35,32 @@ func main() {
 		dsn = defaultDSN
 	}
 
-	if err := execute(net.JoinHostPort(host, port), dsn); err != nil {
+	hasher, err := passwordHasher()
+	if err != nil {
+		log.Print(err)
+		os.Exit(1)
+	}
+
+	if err := execute(net.JoinHostPort(host, port), dsn, hasher); err != nil {
 		os.Exit(1)
 	}
 }
 
-func execute(addr string, dsn string) error {
+// passwordHasher - алгоритм для новых хэшей из APP_PASSWORD_HASH (bcrypt, argon2id или scrypt)
+func passwordHasher() (auth.PasswordHasher, error) {
+	switch algorithm := os.Getenv("APP_PASSWORD_HASH"); algorithm {
+	case "", "bcrypt":
+		return &auth.BcryptHasher{}, nil
+	case "argon2id":
+		return &auth.Argon2idHasher{}, nil
+	case "scrypt":
+		return &auth.ScryptHasher{}, nil
+	default:
+		return nil, fmt.Errorf("APP_PASSWORD_HASH must be bcrypt, argon2id or scrypt, got %q", algorithm)
+	}
+}
+
+func execute(addr string, dsn string, hasher auth.PasswordHasher) error {
 	ctx := context.Background()
 	pool, err := pgxpool.Connect(ctx, dsn)
 	if err != nil {
@@ -48,7 +69,7 @@ func execute(addr string, dsn string) error {
 	}
 	defer pool.Close()
 
-	authSvc := auth.NewService(pool)
+	authSvc := auth.NewService(pool, auth.WithPasswordHasher(hasher))
 	mux := chi.NewRouter()
 
 	application := app.NewServer(authSvc, mux)
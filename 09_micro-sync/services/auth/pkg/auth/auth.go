@@ -6,7 +6,7 @@ import (
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v4"
 	"github.com/jackc/pgx/v4/pgxpool"
-	"golang.org/x/crypto/bcrypt"
+	"log"
 )
 
 var ErrUserNotFound = errors.New("user not found")
@@ -14,32 +14,47 @@ var ErrInvalidPass = errors.New("invalid password")
 
 type Service struct {
 	pool *pgxpool.Pool
+	// hasher - предпочтительный алгоритм: хэши других алгоритмов пересчитываются им при входе
+	hasher PasswordHasher
 }
 
-func NewService(pool *pgxpool.Pool) *Service {
-	return &Service{pool: pool}
+type Option func(service *Service)
+
+// WithPasswordHasher задаёт предпочтительный алгоритм хэширования паролей (по умолчанию bcrypt)
+func WithPasswordHasher(hasher PasswordHasher) Option {
+	return func(service *Service) {
+		service.hasher = hasher
+	}
+}
+
+func NewService(pool *pgxpool.Pool, options ...Option) *Service {
+	service := &Service{pool: pool, hasher: &BcryptHasher{}}
+	for _, option := range options {
+		option(service)
+	}
+	return service
 }
 
 func (s *Service) Login(ctx context.Context, login string, password string) (string, error) {
 	var userID int64
-	var hash []byte
+	var hash string
 	err := s.pool.QueryRow(ctx, `
 		SELECT id, password FROM users WHERE login = $1
 	`, login).Scan(&userID, &hash)
 	if err != nil {
-		if err != pgx.ErrNoRows {
+		if errors.Is(err, pgx.ErrNoRows) {
 			return "", ErrUserNotFound
 		}
 		return "", err
 	}
 
-	err = bcrypt.CompareHashAndPassword(hash, []byte(password))
+	newHash, err := s.checkPassword(hash, password)
 	if err != nil {
-		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
-			return "", ErrInvalidPass
-		}
 		return "", err
 	}
+	if newHash != "" {
+		s.updateHash(ctx, userID, hash, newHash)
+	}
 
 	token := uuid.New().String()
 	_, err = s.pool.Exec(ctx, `INSERT INTO tokens (token, userid) VALUES ($1, $2)`, token, userID)
@@ -64,3 +79,28 @@ func (s *Service) UserID(ctx context.Context, token string) (userID int64, err e
 	return userID, nil
 }
 
+// checkPassword проверяет пароль и, если хэш нужно пересчитать предпочтительным алгоритмом, возвращает новый хэш.
+// Ошибка пересчёта не мешает входу, она только пишется в журнал.
+func (s *Service) checkPassword(hash string, password string) (string, error) {
+	if err := VerifyPassword(hash, []byte(password)); err != nil {
+		return "", err
+	}
+	if !NeedsUpgrade(s.hasher, hash) {
+		return "", nil
+	}
+	newHash, err := s.hasher.Hash([]byte(password))
+	if err != nil {
+		log.Printf("can't rehash password: %v", err)
+		return "", nil
+	}
+	return newHash, nil
+}
+
+// updateHash сохраняет пересчитанный хэш. Ошибка не мешает входу, она только пишется в журнал.
+func (s *Service) updateHash(ctx context.Context, userID int64, hash string, newHash string) {
+	// условие по старому хэшу - чтобы не затереть пароль, изменённый параллельно
+	_, err := s.pool.Exec(ctx, `UPDATE users SET password = $3 WHERE id = $1 AND password = $2`, userID, hash, newHash)
+	if err != nil {
+		log.Printf("can't update rehashed password: %v", err)
+	}
+}
@@ -22,7 +22,7 @@ func NewServer(authSvc *auth.Service, transactionsSvc *transactions.Service, mux
 }
 
 func (s *Server) Init() error {
-	s.mux.Use(middleware.Logger)
+	s.mux.Use(middleware.RequestID, middleware.Logger)
 
 	s.mux.Route("/api", func(r chi.Router) {
 		r.Post("/token", s.token)
@@ -6,6 +6,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"github.com/go-chi/chi/middleware"
 	"net/http"
 )
 
@@ -42,6 +43,9 @@ func (s *Service) Token(ctx context.Context, login string, password string) (tok
 		return "", err
 	}
 	req.Header.Set("Content-Type", "application/json")
+	if requestID := middleware.GetReqID(ctx); requestID != "" {
+		req.Header.Set(middleware.RequestIDHeader, requestID)
+	}
 
 	resp, err := s.client.Do(req)
 	if err != nil {
@@ -90,6 +94,9 @@ func (s *Service) Auth(ctx context.Context, token string) (userID int64, err err
 		return 0, err
 	}
 	req.Header.Set("Content-Type", "application/json")
+	if requestID := middleware.GetReqID(ctx); requestID != "" {
+		req.Header.Set(middleware.RequestIDHeader, requestID)
+	}
 
 	resp, err := s.client.Do(req)
 	if err != nil {
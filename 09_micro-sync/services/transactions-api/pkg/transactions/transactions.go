@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"github.com/go-chi/chi/middleware"
 	"log"
 	"net/http"
 	"strconv"
@@ -42,6 +43,9 @@ func (s *Service) Transactions(ctx context.Context, userID int64) ([]byte, error
 		return nil, err
 	}
 	req.Header.Set("X-UserId", strconv.FormatInt(userID, 10))
+	if requestID := middleware.GetReqID(ctx); requestID != "" {
+		req.Header.Set(middleware.RequestIDHeader, requestID)
+	}
 
 	resp, err := s.client.Do(req)
 	if err != nil {
@@ -20,7 +20,7 @@ func NewServer(transactionsSvc *transactions.Service, mux chi.Router) *Server {
 }
 
 func (s *Server) Init() error {
-	s.mux.Use(middleware.Logger)
+	s.mux.Use(middleware.RequestID, middleware.Logger)
 
 	s.mux.Route("/api", func(r chi.Router) {
 		r.Get("/transactions", s.transactions)
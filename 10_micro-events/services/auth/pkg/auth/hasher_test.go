@@ -0,0 +1,92 @@
+package auth
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+// слабые параметры, чтобы тесты шли быстро
+var testHashers = []struct {
+	name   string
+	hasher PasswordHasher
+}{
+	{name: "bcrypt", hasher: &BcryptHasher{Cost: 4}},
+	{name: "argon2id", hasher: &Argon2idHasher{Time: 1, Memory: 1024, Threads: 1}},
+	{name: "scrypt", hasher: &ScryptHasher{LogN: 10}},
+}
+
+// hasher.go - копия security/hasher.go, она не должна расходиться с исходным файлом
+func TestHasherCopy(t *testing.T) {
+	script := filepath.Join("..", "..", "..", "..", "..", "01_security", "service", "sync-hashers.sh")
+	if _, err := os.Stat(script); err != nil {
+		t.Skip("source hasher.go is not available (service is built on its own)")
+	}
+	if output, err := exec.Command("sh", script, "-check").CombinedOutput(); err != nil {
+		t.Errorf("%v: %s", err, output)
+	}
+}
+
+func TestPasswordHasher_Verify(t *testing.T) {
+	for _, tt := range testHashers {
+		hash, err := tt.hasher.Hash([]byte("secret"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := VerifyPassword(hash, []byte("secret")); err != nil {
+			t.Errorf("%s: got %v, want nil", tt.name, err)
+		}
+		if err := VerifyPassword(hash, []byte("Secret")); !errors.Is(err, ErrInvalidPass) {
+			t.Errorf("%s: got %v, want %v", tt.name, err, ErrInvalidPass)
+		}
+	}
+}
+
+func TestVerifyPassword_Malformed(t *testing.T) {
+	tests := []struct {
+		name string
+		hash string
+	}{
+		{name: "empty", hash: ""},
+		{name: "plain text", hash: "secret"},
+		{name: "bcrypt truncated", hash: "$2a$04$abc"},
+		{name: "argon2id no key", hash: "$argon2id$v=19$m=1024,t=1,p=1$c2FsdA"},
+		{name: "argon2id bad version", hash: "$argon2id$v=16$m=1024,t=1,p=1$c2FsdA$a2V5"},
+		{name: "argon2id zero time", hash: "$argon2id$v=19$m=1024,t=0,p=1$c2FsdA$a2V5"},
+		{name: "scrypt bad key", hash: "$scrypt$ln=10,r=8,p=1$c2FsdA$!!"},
+		{name: "scrypt too large", hash: "$scrypt$ln=40,r=8,p=1$c2FsdA$a2V5"},
+	}
+
+	for _, tt := range tests {
+		if err := VerifyPassword(tt.hash, []byte("secret")); !errors.Is(err, ErrUnknownHash) {
+			t.Errorf("%s: got %v, want %v", tt.name, err, ErrUnknownHash)
+		}
+	}
+}
+
+func TestNeedsUpgrade(t *testing.T) {
+	preferred := &Argon2idHasher{Time: 1, Memory: 1024, Threads: 1}
+
+	tests := []struct {
+		name   string
+		hashed PasswordHasher
+		want   bool
+	}{
+		{name: "preferred", hashed: preferred, want: false},
+		{name: "weaker parameters", hashed: &Argon2idHasher{Time: 1, Memory: 512, Threads: 1}, want: true},
+		{name: "bcrypt", hashed: &BcryptHasher{Cost: 4}, want: true},
+		{name: "scrypt", hashed: &ScryptHasher{LogN: 10}, want: true},
+	}
+
+	for _, tt := range tests {
+		hash, err := tt.hashed.Hash([]byte("secret"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := NeedsUpgrade(preferred, hash); got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
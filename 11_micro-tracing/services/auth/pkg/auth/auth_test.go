@@ -0,0 +1,58 @@
+package auth
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestService_checkPassword(t *testing.T) {
+	preferred := &Argon2idHasher{Time: 1, Memory: 1024, Threads: 1}
+	service := NewService(nil, WithPasswordHasher(preferred))
+
+	type args struct {
+		hashed   PasswordHasher
+		password string
+	}
+
+	tests := []struct {
+		name    string
+		args    args
+		rehash  bool
+		wantErr error
+	}{
+		{name: "preferred", args: args{hashed: preferred, password: "secret"}},
+		{name: "bcrypt upgraded", args: args{hashed: &BcryptHasher{Cost: 4}, password: "secret"}, rehash: true},
+		{name: "scrypt upgraded", args: args{hashed: &ScryptHasher{LogN: 10}, password: "secret"}, rehash: true},
+		{name: "weaker parameters upgraded", args: args{hashed: &Argon2idHasher{Time: 1, Memory: 512, Threads: 1}, password: "secret"}, rehash: true},
+		{name: "invalid password", args: args{hashed: &BcryptHasher{Cost: 4}, password: "Secret"}, wantErr: ErrInvalidPass},
+	}
+
+	for _, tt := range tests {
+		hash, err := tt.args.hashed.Hash([]byte("secret"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		newHash, err := service.checkPassword(hash, tt.args.password)
+		if !errors.Is(err, tt.wantErr) {
+			t.Errorf("%s: got %v, want %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if rehash := newHash != ""; rehash != tt.rehash {
+			t.Errorf("%s: got rehash %v, want %v", tt.name, rehash, tt.rehash)
+			continue
+		}
+		if !tt.rehash {
+			continue
+		}
+		if NeedsUpgrade(preferred, newHash) {
+			t.Errorf("%s: new hash %v is not preferred", tt.name, newHash)
+		}
+		if err := VerifyPassword(newHash, []byte(tt.args.password)); err != nil {
+			t.Errorf("%s: new hash does not match password: %v", tt.name, err)
+		}
+	}
+
+	if _, err := service.checkPassword("secret", "secret"); !errors.Is(err, ErrUnknownHash) {
+		t.Errorf("got %v, want %v", err, ErrUnknownHash)
+	}
+}